@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -72,6 +73,16 @@ func init() {
 
 	// Config file contents
 	addFlag("round_time", "r", "30s", "time to wait between rounds of checks")
+	addFlag("round_jitter", "", "0s", "maximum amount of time to randomly add to or subtract from each round_time")
+	addInt64Flag("seed", "", 0, "seed for random check ordering and timing, or 0 to pick one at startup")
+	addFlag("overlap", "o", "skip", "what to do when a round is due while the previous round is still running - one of skip, queue, or allow")
+	addFlag("start_spread", "", "0s", "window of time at the start of each round during which checks will start at random times")
+	addIntFlag("max_concurrency", "", 0, "maximum number of checks to run at the same time, or 0 for no limit")
+	addMapFlag("max_concurrency_per_type", "", nil, "maximum number of checks of each type to run at the same time, like http=10,ssh=5")
+	addFlag("timeout", "", check.DefaultTimeout.String(), "time limit for checks that don't set their own timeout")
+	addFlag("exec_dir", "", "", "directory containing the commands that exec checks may run; exec checks are disabled if unset")
+	addSliceFlag("exec_env", "", nil, "environment variables to give exec commands besides PATH, as NAME=value or NAME to copy it from Dynamicbeat's environment")
+	addFlag("files_dir", "", "", "directory containing files that checks may read, like HTTP request bodies")
 	addFlag("elasticsearch", "e", "https://localhost:9200", "address of Elasticsearch host to pull checks from and store results in")
 	addFlag("username", "u", "dynamicbeat", "username for authentication with Elasticsearch")
 	addFlag("password", "p", "changeme", "password for authentication with Elasticsearch")
@@ -93,6 +104,16 @@ func addFlag(name string, short string, value string, help string) {
 	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
 }
 
+func addIntFlag(name string, short string, value int, help string) {
+	rootCmd.PersistentFlags().IntP(name, short, value, help)
+	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+}
+
+func addInt64Flag(name string, short string, value int64, help string) {
+	rootCmd.PersistentFlags().Int64P(name, short, value, help)
+	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+}
+
 func addInt8Flag(name string, short string, value int8, help string) {
 	rootCmd.PersistentFlags().Int8P(name, short, value, help)
 	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
@@ -103,6 +124,16 @@ func addBoolFlag(name string, short string, value bool, help string) {
 	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
 }
 
+func addSliceFlag(name string, short string, value []string, help string) {
+	rootCmd.PersistentFlags().StringSliceP(name, short, value, help)
+	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+}
+
+func addMapFlag(name string, short string, value map[string]string, help string) {
+	rootCmd.PersistentFlags().StringToStringP(name, short, value, help)
+	_ = viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name))
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/spf13/cobra"
+
+	// Register the built-in check types
+	_ "github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes"
+)
+
+const typesShort = "List the available check types."
+const typesLong = typesShort + `
+
+Lists the name and a short description of each check type. If the name of a
+check type is passed, the options accepted by that check type's definition are
+listed instead.`
+
+// typesCmd represents the types command
+var typesCmd = &cobra.Command{
+	Use:   "types [check type]",
+	Short: typesShort,
+	Long:  typesLong,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+
+		if len(args) == 0 {
+			fmt.Fprintln(w, "TYPE\tDESCRIPTION")
+			for _, t := range check.Types() {
+				fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Description)
+			}
+			return
+		}
+
+		t, ok := check.Lookup(args[0])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("unknown check type '%s'", args[0]))
+		}
+
+		fmt.Fprintln(w, "OPTION\tKIND\tREQUIRED\tDEFAULT")
+		printFields(w, t.Schema(), "")
+	},
+}
+
+func printFields(w *tabwriter.Writer, fields []check.Field, prefix string) {
+	for _, f := range fields {
+		required := "N"
+		if f.Option == "required" {
+			required = "Y"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", prefix, f.Name, f.Kind, required, f.Default)
+
+		// List options are shown with the options for each item indented
+		// beneath them
+		if len(f.Children) > 0 {
+			printFields(w, f.Children, prefix+strings.Repeat(" ", 2))
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(typesCmd)
+}
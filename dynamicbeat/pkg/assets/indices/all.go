@@ -6,6 +6,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/assets"
 )
 
+func Events() io.Reader {
+	return assets.Read("indices/events.json")
+}
+
+func Rounds() io.Reader {
+	return assets.Read("indices/rounds.json")
+}
+
 func ResultsAdmin() io.Reader {
 	return assets.Read("indices/results-admin.json")
 }
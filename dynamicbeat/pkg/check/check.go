@@ -6,14 +6,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
+	"time"
 )
 
+// DefaultTimeout is the amount of time a check is given to finish when
+// neither the check nor the Dynamicbeat configuration sets a timeout.
+const DefaultTimeout = 25 * time.Second
+
 type Check interface {
 	GetConfig() Config
 	SetConfig(c Config)
 	Run(ctx context.Context) Result
 }
 
+// A RuntimeChecker is a check that fills in some values in its definition
+// itself while it runs. Templates in the definition that refer to those values
+// are rendered as references like ${name} for the check to expand, unless an
+// attribute has the same name.
+type RuntimeChecker interface {
+	Check
+	RuntimeVariables() []string
+}
+
 type Metadata struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -22,8 +37,69 @@ type Metadata struct {
 	ScoreWeight int64  `json:"score_weight"`
 }
 
+// A Policy controls how a check is run. It is part of the check's
+// configuration, but unlike the Metadata it isn't included in check results.
+type Policy struct {
+	Timeout string `json:"timeout,omitempty"`
+	Retry   *Retry `json:"retry,omitempty"`
+	SLO     SLO    `json:"slo,omitempty"`
+}
+
+// A Retry policy configures whether a failed check will be run again within
+// the same round.
+type Retry struct {
+	Attempts int      `json:"attempts"`          // the maximum number of times to run the check, including the first attempt
+	Backoff  string   `json:"backoff,omitempty"` // how long to wait before the second attempt; doubles after each attempt
+	On       []string `json:"on,omitempty"`      // the failure classes that will be retried; if empty, all failures are retried
+}
+
+// GetBackoff parses the retry policy's backoff. If the policy does not set a
+// backoff, one second is used.
+func (r *Retry) GetBackoff() (time.Duration, error) {
+	if r.Backoff == "" {
+		return time.Second, nil
+	}
+
+	backoff, err := time.ParseDuration(r.Backoff)
+	if err != nil {
+		return time.Second, fmt.Errorf("failed to parse retry backoff: %s", err)
+	}
+
+	return backoff, nil
+}
+
+// Validate makes sure the retry policy only retries known failure classes.
+func (r *Retry) Validate() error {
+	for _, on := range r.On {
+		if !Failure(on).Known() {
+			return fmt.Errorf("unknown failure class '%s' in retry policy", on)
+		}
+	}
+
+	return nil
+}
+
+// GetTimeout parses the check's timeout. If the check does not set a timeout,
+// the provided default is returned instead.
+func (c *Config) GetTimeout(dflt time.Duration) (time.Duration, error) {
+	if c.Timeout == "" {
+		return dflt, nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return dflt, fmt.Errorf("failed to parse timeout for '%s': %s", c.ID, err)
+	}
+	if timeout <= 0 {
+		return dflt, fmt.Errorf("timeout for '%s' must be positive, got '%s'", c.ID, c.Timeout)
+	}
+
+	return timeout, nil
+}
+
 type Config struct {
 	Metadata
+	Policy
 	Definition []byte
 	Attributes `json:"attributes"`
 }
@@ -46,6 +122,48 @@ func (a *Attributes) Merged() map[string]string {
 	return m
 }
 
+// Remaining returns the amount of time left before the context's deadline.
+// Check types should use this to derive their dial, read, and protocol
+// timeouts so that they all fit within the check's timeout. If the context
+// has no deadline, DefaultTimeout is returned.
+func Remaining(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return DefaultTimeout
+	}
+
+	return time.Until(deadline)
+}
+
+// Deadline returns the context's deadline. If the context has no deadline,
+// a deadline DefaultTimeout from now is returned.
+func Deadline(ctx context.Context) time.Time {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return time.Now().Add(DefaultTimeout)
+	}
+
+	return deadline
+}
+
+// Dial connects to the address on the named network and sets the
+// connection's deadline to the context's, so that every read and write on
+// the connection obeys the check's timeout.
+func Dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetDeadline(Deadline(ctx))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
 // A ValidationError represents an issue with a check definition.
 type ValidationError struct {
 	ID    string // the ID of the check with an invalid definition
@@ -67,8 +185,9 @@ func (c *Config) Documents() (io.Reader, io.Reader, io.Reader, io.Reader, error)
 	// The check definition document doesn't include the attributes
 	chk := struct {
 		Metadata
+		Policy
 		Definition map[string]interface{} `json:"definition"`
-	}{c.Metadata, def}
+	}{c.Metadata, c.Policy, def}
 	checkDoc, err := json.Marshal(chk)
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("failed to marshal definition for '%s': %s", c.ID, err)
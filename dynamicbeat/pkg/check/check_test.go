@@ -0,0 +1,170 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", "", 10 * time.Second, false},
+		{"set", "3s", 3 * time.Second, false},
+		{"invalid", "soon", 10 * time.Second, true},
+		{"zero", "0s", 10 * time.Second, true},
+		{"negative", "-5s", 10 * time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Metadata: Metadata{ID: "check"}, Policy: Policy{Timeout: tt.timeout}}
+			got, err := c.GetTimeout(10 * time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	// Without a deadline, the default timeout is used
+	if got := Remaining(context.Background()); got != DefaultTimeout {
+		t.Errorf("Remaining() = %s, want %s", got, DefaultTimeout)
+	}
+	if got := time.Until(Deadline(context.Background())); got <= DefaultTimeout-time.Second || got > DefaultTimeout {
+		t.Errorf("Deadline() is %s away, want about %s", got, DefaultTimeout)
+	}
+
+	// With a deadline, the context's deadline is used
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	if got := Deadline(ctx); !got.Equal(want) {
+		t.Errorf("Deadline() = %s, want %s", got, want)
+	}
+	if got := Remaining(ctx); got <= 0 || got > 2*time.Second {
+		t.Errorf("Remaining() = %s, want at most 2s", got)
+	}
+}
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// Reads from a server that never replies stop at the context's deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	conn, err := Dial(ctx, "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() failed: %s", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 1))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() error = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestResultsExcludePolicy(t *testing.T) {
+	c := Config{
+		Metadata: Metadata{ID: "check", Group: "team01"},
+		Policy:   Policy{Timeout: "5s", Retry: &Retry{Attempts: 3}, SLO: SLO{"total": "1s"}},
+	}
+	r := Result{Metadata: c.Metadata, Timestamp: time.Now()}
+
+	for _, doc := range []func() (string, io.Reader, error){r.Generic, r.Team, r.Admin} {
+		index, body, err := doc()
+		if err != nil {
+			t.Fatalf("failed to create result document: %s", err)
+		}
+		b, _ := io.ReadAll(body)
+		for _, field := range []string{`"timeout"`, `"retry"`, `"slo"`} {
+			if bytes.Contains(b, []byte(field)) {
+				t.Errorf("%s document includes %s: %s", index, field, b)
+			}
+		}
+	}
+}
+
+func TestDocumentsIncludePolicy(t *testing.T) {
+	c := Config{
+		Metadata:   Metadata{ID: "check", Group: "team01"},
+		Policy:     Policy{Timeout: "5s"},
+		Definition: []byte(`{}`),
+	}
+
+	chk, generic, _, _, err := c.Documents()
+	if err != nil {
+		t.Fatalf("Documents() error = %s", err)
+	}
+	if b, _ := io.ReadAll(chk); !bytes.Contains(b, []byte(`"timeout":"5s"`)) {
+		t.Errorf("check definition document doesn't include the timeout: %s", b)
+	}
+	if b, _ := io.ReadAll(generic); bytes.Contains(b, []byte(`"timeout"`)) {
+		t.Errorf("generic check document includes the timeout: %s", b)
+	}
+}
+
+func TestGetBackoff(t *testing.T) {
+	cases := []struct {
+		name    string
+		backoff string
+		want    time.Duration
+		err     bool
+	}{
+		{"Default", "", time.Second, false},
+		{"Set", "250ms", 250 * time.Millisecond, false},
+		{"Invalid", "soon", time.Second, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := (&Retry{Backoff: c.backoff}).GetBackoff()
+			if (err != nil) != c.err {
+				t.Errorf("GetBackoff() error = %v, want error: %t", err, c.err)
+			}
+			if got != c.want {
+				t.Errorf("GetBackoff() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRetryValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		on   []string
+		err  bool
+	}{
+		{"Empty", nil, false},
+		{"Known", []string{"timeout", "connect_refused"}, false},
+		{"Unknown", []string{"timeout", "timed_out"}, true},
+		{"None", []string{""}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := (&Retry{On: c.on}).Validate()
+			if (err != nil) != c.err {
+				t.Errorf("Validate() error = %v, want error: %t", err, c.err)
+			}
+		})
+	}
+}
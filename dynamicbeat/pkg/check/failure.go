@@ -0,0 +1,87 @@
+package check
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// A Failure describes why a check failed.
+type Failure string
+
+const (
+	None            Failure = ""                 // the check passed
+	DNSResolution   Failure = "dns_resolution"   // the service's hostname couldn't be resolved
+	ConnectRefused  Failure = "connect_refused"  // the service refused the connection
+	Timeout         Failure = "timeout"          // the service didn't respond in time
+	TLS             Failure = "tls"              // the TLS handshake or certificate validation failed
+	Auth            Failure = "auth"             // the service rejected the credentials
+	Protocol        Failure = "protocol"         // the service didn't speak the protocol as expected
+	ContentMismatch Failure = "content_mismatch" // the service responded, but with the wrong content
+	DefinitionError Failure = "definition_error" // the check definition is invalid
+	Slow            Failure = "slow"             // the check passed, but took longer than its SLO allows
+)
+
+// Known reports whether the Failure is one of the classes a failed check can
+// be reported as.
+func (f Failure) Known() bool {
+	switch f {
+	case DNSResolution, ConnectRefused, Timeout, TLS, Auth, Protocol, ContentMismatch, DefinitionError, Slow:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify determines the Failure for an error returned by a network
+// operation. If the error isn't caused by DNS resolution, a refused
+// connection, a timeout, or TLS, the fallback is returned instead.
+func Classify(err error, fallback Failure) Failure {
+	if err == nil {
+		return fallback
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return DNSResolution
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ConnectRefused
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return Timeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout
+	}
+	var certErr *tls.CertificateVerificationError
+	var headerErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &headerErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return TLS
+	}
+
+	// Many client libraries don't wrap the errors they encounter, so fall
+	// back to looking at the error message
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "no such host"), strings.Contains(msg, "server misbehaving"):
+		return DNSResolution
+	case strings.Contains(msg, "connection refused"):
+		return ConnectRefused
+	case strings.Contains(msg, "i/o timeout"), strings.Contains(msg, "timed out"), strings.Contains(msg, "deadline exceeded"):
+		return Timeout
+	case strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+		return TLS
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,41 @@
+package check
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want Failure
+	}{
+		{"Nil", nil, Protocol},
+		{"DNS", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}, DNSResolution},
+		{"Refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ConnectRefused},
+		{"Deadline", fmt.Errorf("query failed: %w", context.DeadlineExceeded), Timeout},
+		{"DeadlineExceeded", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, Timeout},
+		{"UnknownAuthority", fmt.Errorf("handshake failed: %w", x509.UnknownAuthorityError{}), TLS},
+		{"Hostname", x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}, TLS},
+		{"MessageDNS", errors.New("dial tcp: lookup db.team01: server misbehaving"), DNSResolution},
+		{"MessageRefused", errors.New("dial tcp 10.0.0.1:3306: connect: Connection refused"), ConnectRefused},
+		{"MessageTimeout", errors.New("read tcp 10.0.0.1:22: i/o timeout"), Timeout},
+		{"MessageTLS", errors.New("remote error: tls: bad certificate"), TLS},
+		{"Other", errors.New("530 Login incorrect"), Protocol},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Classify(c.err, Protocol); got != c.want {
+				t.Errorf("Classify() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package check
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// A Type describes a kind of check that Dynamicbeat can run. Each check type
+// package registers its Type when it is imported.
+type Type struct {
+	Name        string       // the name used in the type field of check definitions
+	Description string       // a short summary of what the check does
+	New         func() Check // creates an empty definition for the check type
+}
+
+// A Field describes one option in a check type's definition.
+type Field struct {
+	Name     string  // the name of the option in check definitions
+	Kind     string  // the Go kind of the option's value
+	Option   string  // whether the option is required, optional, or a list
+	Default  string  // the value used for an optional option that isn't set
+	Children []Field // the options for each item of a list option
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[string]Type)
+)
+
+// Register makes a check type available to Dynamicbeat. It panics if the type
+// is missing a name or constructor, or if a type with the same name has
+// already been registered.
+func Register(t Type) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	if t.Name == "" || t.New == nil {
+		panic("check: Register called with an incomplete check type")
+	}
+	if _, exists := types[t.Name]; exists {
+		panic(fmt.Sprintf("check: Register called twice for check type '%s'", t.Name))
+	}
+
+	types[t.Name] = t
+}
+
+// Lookup finds the registered check type with the given name.
+func Lookup(name string) (Type, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	t, ok := types[name]
+	return t, ok
+}
+
+// Types returns all registered check types, sorted by name.
+func Types() []Type {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	out := make([]Type, 0, len(types))
+	for _, t := range types {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+}
+
+// Schema describes the options accepted by the check type's definition, as
+// declared by the optiontype and optiondefault struct tags.
+func (t Type) Schema() []Field {
+	return schemaOf(reflect.TypeOf(t.New()))
+}
+
+func schemaOf(typ reflect.Type) []Field {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []Field
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		option := field.Tag.Get("optiontype")
+		if option == "" {
+			continue
+		}
+
+		f := Field{
+			Name:    field.Name,
+			Kind:    field.Type.Kind().String(),
+			Option:  option,
+			Default: field.Tag.Get("optiondefault"),
+		}
+		if option == "list" {
+			f.Children = schemaOf(field.Type)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// An UnknownTypeError is returned when a check definition has a type that
+// hasn't been registered.
+type UnknownTypeError struct {
+	ID   string // the ID of the check with an unknown type
+	Type string // the unknown type
+}
+
+func (u UnknownTypeError) Error() string {
+	return fmt.Sprintf("Error: check (Type: `%s`, ID: `%s`) has an unknown type", u.Type, u.ID)
+}
@@ -0,0 +1,85 @@
+package check
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type testDefinition struct {
+	Config   Config
+	Host     string      `optiontype:"required"`
+	Port     string      `optiontype:"optional" optiondefault:"80"`
+	Commands []*testStep `optiontype:"list"`
+}
+
+type testStep struct {
+	Command string `optiontype:"required"`
+	Expect  string `optiontype:"optional" optiondefault:".*"`
+}
+
+func (d *testDefinition) GetConfig() Config              { return d.Config }
+func (d *testDefinition) SetConfig(c Config)             { d.Config = c }
+func (d *testDefinition) Run(ctx context.Context) Result { return Result{} }
+
+// unregister removes a check type that a test registered, so the test can be
+// run more than once.
+func unregister(name string) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	delete(types, name)
+}
+
+func TestRegister(t *testing.T) {
+	typ := Type{Name: "registry-test", Description: "a check for testing", New: func() Check { return &testDefinition{} }}
+	Register(typ)
+	t.Cleanup(func() { unregister(typ.Name) })
+
+	got, ok := Lookup("registry-test")
+	if !ok || got.Description != typ.Description {
+		t.Fatalf("Lookup() = %v, %t, want the registered type", got, ok)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Error("Lookup() found a type that was never registered")
+	}
+
+	found := false
+	for _, registered := range Types() {
+		found = found || registered.Name == "registry-test"
+	}
+	if !found {
+		t.Error("Types() is missing the registered type")
+	}
+
+	for name, typ := range map[string]Type{
+		"Duplicate": typ,
+		"NoName":    {New: typ.New},
+		"NoNew":     {Name: "registry-test-2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Register() did not panic")
+				}
+			}()
+			Register(typ)
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	typ := Type{Name: "schema-test", New: func() Check { return &testDefinition{} }}
+	want := []Field{
+		{Name: "Host", Kind: "string", Option: "required"},
+		{Name: "Port", Kind: "string", Option: "optional", Default: "80"},
+		{Name: "Commands", Kind: "slice", Option: "list", Children: []Field{
+			{Name: "Command", Kind: "string", Option: "required"},
+			{Name: "Expect", Kind: "string", Option: "optional", Default: ".*"},
+		}},
+	}
+
+	if got := typ.Schema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Schema() = %+v, want %+v", got, want)
+	}
+}
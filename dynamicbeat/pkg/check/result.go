@@ -10,15 +10,22 @@ import (
 
 type Result struct {
 	Metadata
+	Round     uint64
 	Timestamp time.Time
 	Passed    bool
+	Failure   Failure
 	Message   string
 	Details   map[string]string
+	Duration  time.Duration // how long the last attempt took
+	Timings   Timings       // phase timings of the last attempt
+	Attempts  int           // how many times the check was run
+	Total     time.Duration // how long all the attempts took, including the waits between them
 }
 
 type generic struct {
 	Metadata
 	Timestamp string `json:"@timestamp"`
+	Round     uint64 `json:"round"`
 	Passed    bool   `json:"passed"`
 	PassedInt uint8  `json:"passed_int"`
 	Epoch     int64  `json:"epoch"`
@@ -28,6 +35,7 @@ func newGeneric(r *Result) generic {
 	out := generic{
 		Metadata:  r.Metadata,
 		Timestamp: r.Timestamp.Format(time.RFC3339),
+		Round:     r.Round,
 		Passed:    r.Passed,
 		PassedInt: 0,
 		Epoch:     r.Timestamp.Unix(),
@@ -42,16 +50,43 @@ func newGeneric(r *Result) generic {
 
 type full struct {
 	generic
-	Message string            `json:"message"`
-	Details map[string]string `json:"details"`
+	Failure    Failure            `json:"failure,omitempty"`
+	Message    string             `json:"message"`
+	Details    map[string]string  `json:"details"`
+	DurationMs float64            `json:"duration_ms"`
+	Timings    map[string]float64 `json:"timings,omitempty"`
+	Attempts   int                `json:"attempts"`
+	TotalMs    float64            `json:"total_duration_ms"`
 }
 
 func newFull(r *Result) full {
-	return full{
-		generic: newGeneric(r),
-		Message: r.Message,
-		Details: r.Details,
+	out := full{
+		generic:    newGeneric(r),
+		Message:    r.Message,
+		Details:    r.Details,
+		DurationMs: milliseconds(r.Duration),
+		Attempts:   r.Attempts,
+		TotalMs:    milliseconds(r.Total),
 	}
+
+	// Phase timings are only recorded by some check types
+	if len(r.Timings) > 0 {
+		out.Timings = make(map[string]float64, len(r.Timings))
+		for phase, d := range r.Timings {
+			out.Timings[phase] = milliseconds(d)
+		}
+	}
+
+	// Only failed checks have a failure category
+	if !r.Passed {
+		out.Failure = r.Failure
+	}
+
+	return out
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
 }
 
 func marshalError(err error) (string, io.Reader, error) {
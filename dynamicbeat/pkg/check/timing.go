@@ -0,0 +1,120 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timings records how long each phase of a check took, such as resolving the
+// service's hostname, connecting to the service, or authenticating.
+type Timings map[string]time.Duration
+
+// Time records the amount of time that has passed since the start of a phase.
+// If the phase has already been recorded, the time is added to it.
+func (r *Result) Time(phase string, start time.Time) {
+	r.Record(phase, time.Since(start))
+}
+
+// Record adds a duration to the time recorded for a phase.
+func (r *Result) Record(phase string, d time.Duration) {
+	if r.Timings == nil {
+		r.Timings = make(Timings)
+	}
+	r.Timings[phase] += d
+}
+
+// A DialTimer dials connections and measures how long it took to establish
+// the first successful one. It can be given to client libraries that dial
+// their own connections, so that connecting can be timed separately from the
+// rest of the protocol setup.
+type DialTimer struct {
+	mu   sync.Mutex
+	took time.Duration
+	done bool
+}
+
+// DialContext connects to the address on the named network.
+func (t *DialTimer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some libraries dial several addresses at once and use the first
+	// connection that succeeds
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.took = time.Since(start)
+		t.done = true
+	}
+
+	return conn, nil
+}
+
+// Took returns how long the first successful connection took to establish.
+func (t *DialTimer) Took() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.took
+}
+
+// TotalPhase is the name used in an SLO for the total duration of the check.
+const TotalPhase = "total"
+
+// An SLO sets the maximum amount of time a passing check may take. Each key is
+// either TotalPhase or the name of a phase recorded in the check's Timings,
+// and each value is a duration string like "500ms".
+type SLO map[string]string
+
+// Thresholds parses the maximum duration for each phase in the SLO.
+func (s SLO) Thresholds() (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration, len(s))
+	for phase, limit := range s {
+		d, err := time.ParseDuration(limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SLO threshold for '%s': %s", phase, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("SLO threshold for '%s' must be positive, got '%s'", phase, limit)
+		}
+		thresholds[phase] = d
+	}
+
+	return thresholds, nil
+}
+
+// Enforce fails a passing result if the check or any of its phases took longer
+// than the thresholds parsed from an SLO allow. Phases that weren't recorded
+// are ignored.
+func Enforce(r *Result, thresholds map[string]time.Duration) {
+	if len(thresholds) == 0 || !r.Passed {
+		return
+	}
+
+	// Check the phases in a consistent order so the message is stable
+	phases := make([]string, 0, len(thresholds))
+	for phase := range thresholds {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+
+	for _, phase := range phases {
+		took, recorded := r.Timings[phase]
+		if phase == TotalPhase {
+			took, recorded = r.Duration, true
+		}
+
+		if recorded && took > thresholds[phase] {
+			r.Passed = false
+			r.Failure = Slow
+			r.Message = fmt.Sprintf("%s took %s, longer than the %s allowed", phase, took.Round(time.Millisecond), thresholds[phase])
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package check
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	var r Result
+	r.Record("connect", 2*time.Millisecond)
+	r.Record("connect", 3*time.Millisecond)
+
+	if r.Timings["connect"] != 5*time.Millisecond {
+		t.Errorf("connect = %s, want 5ms", r.Timings["connect"])
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	cases := []struct {
+		name string
+		slo  SLO
+		want map[string]time.Duration
+		err  bool
+	}{
+		{"Empty", nil, map[string]time.Duration{}, false},
+		{"Valid", SLO{"total": "1s", "connect": "50ms"}, map[string]time.Duration{"total": time.Second, "connect": 50 * time.Millisecond}, false},
+		{"Invalid", SLO{"total": "fast"}, nil, true},
+		{"Zero", SLO{"connect": "0s"}, nil, true},
+		{"Negative", SLO{"connect": "-1s"}, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.slo.Thresholds()
+			if (err != nil) != c.err {
+				t.Fatalf("Thresholds() error = %v, want error: %t", err, c.err)
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("Thresholds() = %v, want %v", got, c.want)
+			}
+			for phase, d := range c.want {
+				if got[phase] != d {
+					t.Errorf("threshold for %s = %s, want %s", phase, got[phase], d)
+				}
+			}
+		})
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	timings := Timings{"connect": 20 * time.Millisecond, "auth": 200 * time.Millisecond}
+
+	cases := []struct {
+		name       string
+		passed     bool
+		thresholds map[string]time.Duration
+		want       bool
+		message    string
+	}{
+		{"NoSLO", true, nil, true, ""},
+		{"WithinSLO", true, map[string]time.Duration{"connect": 50 * time.Millisecond, TotalPhase: time.Second}, true, ""},
+		{"SlowPhase", true, map[string]time.Duration{"auth": 100 * time.Millisecond}, false, "auth took 200ms, longer than the 100ms allowed"},
+		{"SlowTotal", true, map[string]time.Duration{TotalPhase: 250 * time.Millisecond}, false, "total took 300ms, longer than the 250ms allowed"},
+		{"FirstPhase", true, map[string]time.Duration{"connect": time.Millisecond, "auth": time.Millisecond}, false, "auth took 200ms, longer than the 1ms allowed"},
+		{"Unrecorded", true, map[string]time.Duration{"query": time.Millisecond}, true, ""},
+		{"AlreadyFailed", false, map[string]time.Duration{"auth": time.Millisecond}, false, "refused"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := Result{Passed: c.passed, Duration: 300 * time.Millisecond, Timings: timings}
+			if !c.passed {
+				r.Failure, r.Message = ConnectRefused, "refused"
+			}
+
+			Enforce(&r, c.thresholds)
+			if r.Passed != c.want {
+				t.Errorf("Passed = %t, want %t", r.Passed, c.want)
+			}
+			if c.passed && !c.want && r.Failure != Slow {
+				t.Errorf("Failure = %s, want %s", r.Failure, Slow)
+			}
+			if r.Message != c.message {
+				t.Errorf("Message = %q, want %q", r.Message, c.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package check
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Token returns a random string for checks to write to a service and read
+// back, to verify that the service actually stored what it was given. Each
+// token is unique, so a service can't pass by returning a cached value.
+func Token() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return "scorestack-" + hex.EncodeToString(b)
+}
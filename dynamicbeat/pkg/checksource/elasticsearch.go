@@ -257,6 +257,33 @@ func buildCheckConfig(doc *Document, admin map[string]string, user map[string]st
 		return nil, fmt.Errorf("Error encoding definition for %s to JSON string: %s", doc.ID, err)
 	}
 
+	// The timeout, retry policy, and SLO are optional, so they may not be present
+	// in the document
+	timeout, _ := doc.Source["timeout"].(string)
+	var retry *check.Retry
+	if r, exists := doc.Source["retry"]; exists {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding retry policy for %s to JSON string: %s", doc.ID, err)
+		}
+		err = json.Unmarshal(b, &retry)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding retry policy for %s: %s", doc.ID, err)
+		}
+	}
+
+	var slo check.SLO
+	if s, exists := doc.Source["slo"]; exists {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding SLO for %s to JSON string: %s", doc.ID, err)
+		}
+		err = json.Unmarshal(b, &slo)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding SLO for %s: %s", doc.ID, err)
+		}
+	}
+
 	// Unpack check definition into CheckConfig struct
 	c := &check.Config{
 		Metadata: check.Metadata{
@@ -266,6 +293,11 @@ func buildCheckConfig(doc *Document, admin map[string]string, user map[string]st
 			Group:       doc.Source["group"].(string),
 			ScoreWeight: int64(doc.Source["score_weight"].(float64)),
 		},
+		Policy: check.Policy{
+			Timeout: timeout,
+			Retry:   retry,
+			SLO:     slo,
+		},
 		Definition: def,
 		Attributes: check.Attributes{
 			Admin: admin,
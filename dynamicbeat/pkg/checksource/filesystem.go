@@ -102,6 +102,7 @@ func (f *Filesystem) LoadCheck(id string) (*check.Config, error) {
 
 	checkFile := struct {
 		check.Metadata
+		check.Policy
 		Definition map[string]interface{} `json:"definition"`
 		Attributes struct {
 			Admin map[string]string `json:"admin"`
@@ -135,6 +136,7 @@ func (f *Filesystem) LoadCheck(id string) (*check.Config, error) {
 
 	return &check.Config{
 		Metadata:   checkFile.Metadata,
+		Policy:     checkFile.Policy,
 		Definition: def,
 		Attributes: check.Attributes{
 			Admin: admin,
@@ -3,66 +3,521 @@ package dns
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "dns",
+		Description: "Query a DNS server and validate the records it returns",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// recordTypes are the record types that can be queried.
+var recordTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"TXT":   dns.TypeTXT,
+	"NS":    dns.TypeNS,
+	"SRV":   dns.TypeSRV,
+	"PTR":   dns.TypePTR,
+	"SOA":   dns.TypeSOA,
+}
+
 // The Definition configures the behavior of the DNS check
 // it implements the "check" interface
 type Definition struct {
-	Config     check.Config // generic metadata about the check
-	Server     string       `optiontype:"required"`                    // The IP of the DNS server to query
-	Fqdn       string       `optiontype:"required"`                    // The FQDN of the host you are looking up
-	ExpectedIP string       `optiontype:"required"`                    // The expected IP of the host you are looking up
-	Port       string       `optiontype:"optional" optiondefault:"53"` // The port of the DNS server
+	Config       check.Config // generic metadata about the check
+	Server       string       `optiontype:"required"`                         // The IP of the DNS server to query
+	Fqdn         string       `optiontype:"required"`                         // The FQDN of the host you are looking up, or an IP for PTR lookups
+	ExpectedIP   string       `optiontype:"optional"`                         // The expected IP of the host you are looking up, added to Expected
+	Port         string       `optiontype:"optional" optiondefault:"53"`      // The port of the DNS server
+	RecordType   string       `optiontype:"optional" optiondefault:"A"`       // The type of record to query: A, AAAA, CNAME, MX, TXT, NS, SRV, PTR, or SOA
+	Expected     []string     `optiontype:"optional"`                         // The values the returned records are expected to have
+	Match        string       `optiontype:"optional" optiondefault:"any"`     // Whether any or all of the expected values must be returned
+	Transport    string       `optiontype:"optional" optiondefault:"udp"`     // Whether to send the query over udp or tcp
+	Recursion    string       `optiontype:"optional" optiondefault:"true"`    // Whether to ask the server to resolve the query recursively
+	Rcode        string       `optiontype:"optional" optiondefault:"NOERROR"` // The response code the server is expected to return, like NXDOMAIN
+	DNSSEC       string       `optiontype:"optional" optiondefault:"false"`   // Whether the returned records must have valid DNSSEC signatures
+	TrustAnchors []string     `optiontype:"optional"`                         // DS records for the keys that DNSSEC signatures must chain up to
+	TransferZone string       `optiontype:"optional"`                         // A zone the server must refuse to transfer
 }
 
 // Run a single instance of the check
-// For now we only support A record querries
 func (d *Definition) Run(ctx context.Context) check.Result {
 	// Initialize empty result
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
+	// Validate the definition before sending any queries
+	qtype, ok := recordTypes[strings.ToUpper(d.RecordType)]
+	if !ok {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid RecordType '%s' : must be one of A, AAAA, CNAME, MX, TXT, NS, SRV, PTR, or SOA", d.RecordType)
+		return result
+	}
+	rcode, ok := dns.StringToRcode[strings.ToUpper(d.Rcode)]
+	if !ok {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid Rcode '%s'", d.Rcode)
+		return result
+	}
+	if d.Transport != "udp" && d.Transport != "tcp" {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid Transport '%s' : must be either udp or tcp", d.Transport)
+		return result
+	}
+	if d.Match != "any" && d.Match != "all" {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid Match '%s' : must be either any or all", d.Match)
+		return result
+	}
+	recursion, _ := strconv.ParseBool(d.Recursion)
+	dnssec, _ := strconv.ParseBool(d.DNSSEC)
+	anchors := make([]*dns.DS, 0, len(d.TrustAnchors))
+	for _, anchor := range d.TrustAnchors {
+		rr, err := dns.NewRR(anchor)
+		ds, ok := rr.(*dns.DS)
+		if err != nil || !ok {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Invalid TrustAnchor '%s' : must be a DS record", anchor)
+			return result
+		}
+		anchors = append(anchors, ds)
+	}
+	if len(anchors) > 0 && !dnssec {
+		result.Failure = check.DefinitionError
+		result.Message = "TrustAnchors can only be used when DNSSEC is enabled"
+		return result
+	}
+	expected := d.Expected
+	if d.ExpectedIP != "" {
+		expected = append([]string{d.ExpectedIP}, expected...)
+	}
+
 	// Setup for dns query
-	var msg dns.Msg
 	fqdn := dns.Fqdn(d.Fqdn)
-	msg.SetQuestion(fqdn, dns.TypeA)
-
-	// Make it obey timeout via deadline
-	// TODO: change this to be relative to the parent context's timeout
-	deadctx, cancel := context.WithDeadline(ctx, time.Now().Add(20*time.Second))
-	defer cancel()
+	if qtype == dns.TypePTR && net.ParseIP(d.Fqdn) != nil {
+		fqdn, _ = dns.ReverseAddr(d.Fqdn)
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(fqdn, qtype)
+	msg.RecursionDesired = recursion
+	if dnssec {
+		msg.SetEdns0(4096, true)
+	}
 
 	// Send the query
-	in, err := dns.ExchangeContext(deadctx, &msg, fmt.Sprintf("%s:%s", d.Server, d.Port))
+	server := net.JoinHostPort(d.Server, d.Port)
+	in, err := exchange(ctx, msg, server, d.Transport, &result)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Problem sending query to %s : %s", d.Server, err)
 		return result
 	}
 
+	// Collect the records of the requested type, skipping any CNAMEs that were
+	// followed to get to them
+	var records []dns.RR
+	var values []string
+	for _, answer := range in.Answer {
+		if answer.Header().Rrtype == qtype {
+			records = append(records, answer)
+			values = append(values, value(answer))
+		}
+	}
+	result.Details = map[string]string{
+		"rcode":   dns.RcodeToString[in.Rcode],
+		"answers": strings.Join(values, ", "),
+	}
+
+	// Check the response code
+	if in.Rcode != rcode {
+		result.Failure = check.ContentMismatch
+		result.Message = fmt.Sprintf("Server responded with %s, expected %s", dns.RcodeToString[in.Rcode], dns.RcodeToString[rcode])
+		return result
+	}
+
 	// Check if we got any records
-	if len(in.Answer) < 1 {
-		result.Message = fmt.Sprintf("No records received from %s", d.Server)
+	if rcode == dns.RcodeSuccess && len(records) < 1 {
+		result.Failure = check.ContentMismatch
+		result.Message = fmt.Sprintf("No %s records received from %s", dns.TypeToString[qtype], d.Server)
 		return result
 	}
 
-	// Loop through results and check for correct match
-	for _, answer := range in.Answer {
-		// Check if an answer is an A record and it matches the expected IP
-		if a, ok := answer.(*dns.A); ok && (a.A).String() == d.ExpectedIP {
-			// If we reach here the check succeeds
-			result.Passed = true
+	// Check the records against the expected values
+	missing := 0
+	for _, e := range expected {
+		found := false
+		for _, record := range records {
+			if matches(e, record) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing++
+		}
+	}
+	if len(expected) > 0 && (missing == len(expected) || (d.Match == "all" && missing > 0)) {
+		result.Failure = check.ContentMismatch
+		result.Message = "Incorrect Records Returned"
+		return result
+	}
+
+	// Validate the records' signatures
+	if dnssec && len(records) > 0 {
+		start := time.Now()
+		v := &validator{ctx: ctx, server: server, transport: d.Transport, anchors: anchors, keys: make(map[string][]*dns.DNSKEY)}
+		err = v.validate(in.Answer, records)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("DNSSEC validation failed : %s", err)
 			return result
 		}
+		result.Time("dnssec", start)
 	}
 
-	// If we reach here no records matched expected IP and check fails
-	result.Message = "Incorrect Records Returned"
+	// Make sure the server won't transfer the zone
+	if d.TransferZone != "" {
+		start := time.Now()
+		allowed := transferAllowed(ctx, server, d.TransferZone)
+		result.Time("transfer", start)
+		if allowed {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Server allowed a zone transfer of %s", d.TransferZone)
+			return result
+		}
+	}
+
+	// If we reach here the check succeeds
+	result.Passed = true
 	return result
 }
 
+// exchange sends a query to the server and returns its response. Truncated
+// UDP responses are retried over TCP.
+func exchange(ctx context.Context, msg *dns.Msg, server string, transport string, result *check.Result) (*dns.Msg, error) {
+	client := &dns.Client{Net: transport, Timeout: check.Remaining(ctx)}
+	in, rtt, err := client.ExchangeContext(ctx, msg, server)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		result.Record("query", rtt)
+	}
+
+	if in.Truncated && transport == "udp" {
+		return exchange(ctx, msg, server, "tcp", result)
+	}
+
+	return in, nil
+}
+
+// value formats the data of a record, without its header.
+func value(rr dns.RR) string {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A.String()
+	case *dns.AAAA:
+		return r.AAAA.String()
+	case *dns.CNAME:
+		return r.Target
+	case *dns.NS:
+		return r.Ns
+	case *dns.PTR:
+		return r.Ptr
+	case *dns.TXT:
+		return strings.Join(r.Txt, "")
+	default:
+		return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+	}
+}
+
+// matches checks if a record has an expected value. IPs are compared by
+// value, and names are compared without case or trailing dots. MX, SRV, and
+// SOA records can be matched by their full data or by just the name they
+// point to.
+func matches(expected string, rr dns.RR) bool {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A.Equal(net.ParseIP(expected))
+	case *dns.AAAA:
+		return r.AAAA.Equal(net.ParseIP(expected))
+	case *dns.TXT:
+		return expected == value(r)
+	case *dns.MX:
+		if sameName(expected, r.Mx) {
+			return true
+		}
+	case *dns.SRV:
+		if sameName(expected, r.Target) {
+			return true
+		}
+	case *dns.SOA:
+		if sameName(expected, r.Ns) {
+			return true
+		}
+	}
+
+	return sameName(expected, value(rr))
+}
+
+// sameName compares two names, or two lists of fields that may contain names,
+// without case or trailing dots.
+func sameName(a string, b string) bool {
+	normalize := func(s string) []string {
+		fields := strings.Fields(strings.ToLower(s))
+		for i, f := range fields {
+			fields[i] = strings.TrimSuffix(f, ".")
+		}
+		return fields
+	}
+
+	x, y := normalize(a), normalize(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A validator checks DNSSEC signatures, looking up the keys it needs from the
+// same server that answered the query.
+type validator struct {
+	ctx       context.Context
+	server    string
+	transport string
+	anchors   []*dns.DS                // the DS records of the trusted keys; if empty, every zone's keys are trusted
+	keys      map[string][]*dns.DNSKEY // the trusted DNSKEYs of each zone that has been looked up
+}
+
+// validate checks that each set of records is signed by a valid RRSIG from
+// the keys of the zone it belongs to. Without any trust anchors, the zone's
+// keys are trusted as returned by the server.
+func (v *validator) validate(answer []dns.RR, records []dns.RR) error {
+	// Group the records into sets by owner name
+	sets := make(map[string][]dns.RR)
+	for _, record := range records {
+		name := strings.ToLower(record.Header().Name)
+		sets[name] = append(sets[name], record)
+	}
+
+	for _, set := range sets {
+		err := v.verifySet(answer, set)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifySet checks that a set of records is covered by one of the RRSIGs in
+// rrs, made by a trusted key of a zone that contains the records.
+func (v *validator) verifySet(rrs []dns.RR, set []dns.RR) error {
+	name := strings.ToLower(set[0].Header().Name)
+	var lastErr error = fmt.Errorf("no RRSIG records for %s", name)
+	for _, rr := range rrs {
+		sig, ok := rr.(*dns.RRSIG)
+		if !ok || sig.TypeCovered != set[0].Header().Rrtype || strings.ToLower(sig.Header().Name) != name {
+			continue
+		}
+
+		signer := strings.ToLower(sig.SignerName)
+		if !dns.IsSubDomain(signer, name) {
+			lastErr = fmt.Errorf("RRSIG for %s is signed by %s, which does not contain it", name, signer)
+			continue
+		}
+		keys, err := v.zoneKeys(signer)
+		if err != nil {
+			return err
+		}
+
+		lastErr = verify(sig, keys, set)
+		if lastErr == nil {
+			return nil
+		}
+	}
+
+	return lastErr
+}
+
+// zoneKeys looks up the DNSKEYs of a zone. With trust anchors, the keys are
+// only trusted if the DNSKEY set is signed by a key matching a trust anchor
+// for the zone, or a DS record that is itself signed by the parent zone's
+// trusted keys.
+func (v *validator) zoneKeys(zone string) ([]*dns.DNSKEY, error) {
+	if keys, ok := v.keys[zone]; ok {
+		return keys, nil
+	}
+
+	rrs, err := v.lookup(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up DNSKEY for %s: %w", zone, err)
+	}
+	var set []dns.RR
+	var keys []*dns.DNSKEY
+	for _, rr := range rrs {
+		if key, ok := rr.(*dns.DNSKEY); ok && strings.ToLower(key.Header().Name) == zone {
+			set = append(set, key)
+			keys = append(keys, key)
+		}
+	}
+	if len(v.anchors) == 0 {
+		v.keys[zone] = keys
+		return keys, nil
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no DNSKEY records for %s", zone)
+	}
+
+	// Find the DS records for the zone's keys
+	ds, err := v.delegation(zone)
+	if err != nil {
+		return nil, err
+	}
+	var trusted []*dns.DNSKEY
+	for _, key := range keys {
+		for _, d := range ds {
+			digest := key.ToDS(d.DigestType)
+			if digest != nil && digest.KeyTag == d.KeyTag && digest.Algorithm == d.Algorithm && strings.EqualFold(digest.Digest, d.Digest) {
+				trusted = append(trusted, key)
+				break
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("no DNSKEY for %s matches its DS records", zone)
+	}
+
+	// The DNSKEY set must be signed by one of the keys the DS records vouch
+	// for before any of the keys can be trusted
+	err = fmt.Errorf("DNSKEY records for %s are not signed by a trusted key", zone)
+	for _, rr := range rrs {
+		sig, ok := rr.(*dns.RRSIG)
+		if ok && sig.TypeCovered == dns.TypeDNSKEY && strings.ToLower(sig.SignerName) == zone && verify(sig, trusted, set) == nil {
+			err = nil
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v.keys[zone] = keys
+	return keys, nil
+}
+
+// delegation returns the DS records for a zone. The zone's trust anchors are
+// used if there are any. Otherwise, the DS records are looked up, and must be
+// signed by the trusted keys of the zone above.
+func (v *validator) delegation(zone string) ([]*dns.DS, error) {
+	var ds []*dns.DS
+	for _, anchor := range v.anchors {
+		if strings.ToLower(anchor.Header().Name) == zone {
+			ds = append(ds, anchor)
+		}
+	}
+	if len(ds) > 0 {
+		return ds, nil
+	}
+	if zone == "." {
+		return nil, fmt.Errorf("no trust anchor for the root zone")
+	}
+
+	rrs, err := v.lookup(zone, dns.TypeDS)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up DS for %s: %w", zone, err)
+	}
+	var set []dns.RR
+	for _, rr := range rrs {
+		if d, ok := rr.(*dns.DS); ok && strings.ToLower(d.Header().Name) == zone {
+			set = append(set, d)
+			ds = append(ds, d)
+		}
+	}
+	if len(ds) == 0 {
+		return nil, fmt.Errorf("no DS records for %s", zone)
+	}
+
+	err = v.verifySet(rrs, set)
+	if err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+// lookup queries the server for records with DNSSEC signatures.
+func (v *validator) lookup(name string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.SetEdns0(4096, true)
+	in, err := exchange(v.ctx, msg, v.server, v.transport, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return in.Answer, nil
+}
+
+// verify checks a signature over a set of records with the matching key.
+func verify(sig *dns.RRSIG, keys []*dns.DNSKEY, set []dns.RR) error {
+	if !sig.ValidityPeriod(time.Now()) {
+		return fmt.Errorf("RRSIG for %s is expired or not yet valid", sig.Header().Name)
+	}
+	for _, key := range keys {
+		if key.KeyTag() == sig.KeyTag && key.Algorithm == sig.Algorithm {
+			return sig.Verify(key, set)
+		}
+	}
+
+	return fmt.Errorf("no DNSKEY with tag %d for %s", sig.KeyTag, sig.SignerName)
+}
+
+// transferAllowed attempts a zone transfer, and reports whether the server
+// returned any records. Servers that refuse the connection or respond with an
+// error are considered to have refused the transfer.
+func transferAllowed(ctx context.Context, server string, zone string) bool {
+	conn, err := check.Dial(ctx, "tcp", server)
+	if err != nil {
+		return false
+	}
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone))
+	t := &dns.Transfer{Conn: &dns.Conn{Conn: conn}, ReadTimeout: check.Remaining(ctx)}
+	envelopes, err := t.In(msg, server)
+	if err != nil {
+		_ = conn.Close()
+		return false
+	}
+
+	// The channel must be drained so the transfer can finish
+	allowed := false
+	for envelope := range envelopes {
+		if envelope.Error == nil && len(envelope.RR) > 0 {
+			allowed = true
+		}
+	}
+
+	return allowed
+}
+
 // GetConfig returns the current CheckConfig struct this check has been
 // configured with.
 func (d *Definition) GetConfig() check.Config {
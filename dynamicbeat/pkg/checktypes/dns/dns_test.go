@@ -0,0 +1,255 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		record   string
+		expected string
+		want     bool
+	}{
+		{"web.team01.local. 300 IN A 10.0.1.10", "10.0.1.10", true},
+		{"web.team01.local. 300 IN A 10.0.1.10", "10.0.1.11", false},
+		{"web.team01.local. 300 IN AAAA 2001:db8::10", "2001:db8:0::10", true},
+		{"www.team01.local. 300 IN CNAME web.team01.local.", "WEB.team01.local", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "mail.team01.local", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "10 mail.team01.local.", true},
+		{"team01.local. 300 IN MX 10 mail.team01.local.", "20 mail.team01.local", false},
+		{"_ldap._tcp.team01.local. 300 IN SRV 0 100 389 dc.team01.local.", "dc.team01.local", true},
+		{"team01.local. 300 IN TXT \"v=spf1 \" \"-all\"", "v=spf1 -all", true},
+		{"team01.local. 300 IN TXT \"v=spf1 -all\"", "V=SPF1 -ALL", false},
+		{"team01.local. 300 IN SOA ns1.team01.local. admin.team01.local. 1 7200 3600 1209600 300", "ns1.team01.local", true},
+	}
+
+	for _, c := range cases {
+		rr, err := dns.NewRR(c.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matches(c.expected, rr); got != c.want {
+			t.Errorf("matches(%q, %q) = %t, want %t", c.expected, c.record, got, c.want)
+		}
+	}
+}
+
+// serve starts a DNS server on UDP that answers from a fixed set of records,
+// along with the RRSIGs that cover them, and refuses zone transfers. It
+// returns the server's port.
+func serve(t *testing.T, records ...string) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		for _, rr := range rrs {
+			sig, signature := rr.(*dns.RRSIG)
+			if rr.Header().Name == q.Name && (rr.Header().Rrtype == q.Qtype || rr.Header().Rrtype == dns.TypeCNAME || (signature && sig.TypeCovered == q.Qtype)) {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		if len(m.Answer) == 0 {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	return strconv.Itoa(pc.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func TestRun(t *testing.T) {
+	port := serve(t,
+		"web.team01.local. 300 IN A 10.0.1.10",
+		"web.team01.local. 300 IN A 10.0.1.11",
+		"www.team01.local. 300 IN CNAME web.team01.local.",
+		"www.team01.local. 300 IN A 10.0.1.10",
+		"team01.local. 300 IN MX 10 mail.team01.local.",
+		"10.1.0.10.in-addr.arpa. 300 IN PTR web.team01.local.",
+	)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		answers string
+	}{
+		{"ExpectedIP", Definition{Fqdn: "web.team01.local", ExpectedIP: "10.0.1.11"}, true, check.None, "10.0.1.10, 10.0.1.11"},
+		{"Any", Definition{Fqdn: "web.team01.local", Expected: []string{"10.0.1.10", "10.0.1.99"}}, true, check.None, ""},
+		{"All", Definition{Fqdn: "web.team01.local", Expected: []string{"10.0.1.10", "10.0.1.99"}, Match: "all"}, false, check.ContentMismatch, ""},
+		{"Wrong", Definition{Fqdn: "web.team01.local", ExpectedIP: "10.0.2.10"}, false, check.ContentMismatch, ""},
+		{"CNAME", Definition{Fqdn: "www.team01.local", ExpectedIP: "10.0.1.10"}, true, check.None, "10.0.1.10"},
+		{"MX", Definition{Fqdn: "team01.local", RecordType: "mx", Expected: []string{"mail.team01.local"}}, true, check.None, "10 mail.team01.local."},
+		{"PTR", Definition{Fqdn: "10.0.1.10", RecordType: "PTR", Expected: []string{"web.team01.local"}}, true, check.None, "web.team01.local."},
+		{"NXDOMAIN", Definition{Fqdn: "missing.team01.local", Rcode: "NXDOMAIN"}, true, check.None, ""},
+		{"UnexpectedRcode", Definition{Fqdn: "missing.team01.local"}, false, check.ContentMismatch, ""},
+		{"NoRecords", Definition{Fqdn: "web.team01.local", RecordType: "TXT", Rcode: "NOERROR"}, false, check.ContentMismatch, ""},
+		{"RecordType", Definition{Fqdn: "web.team01.local", RecordType: "HINFO"}, false, check.DefinitionError, ""},
+		{"Rcode", Definition{Fqdn: "web.team01.local", Rcode: "NOPE"}, false, check.DefinitionError, ""},
+		{"Transport", Definition{Fqdn: "web.team01.local", Transport: "quic"}, false, check.DefinitionError, ""},
+		{"Match", Definition{Fqdn: "web.team01.local", Match: "some"}, false, check.DefinitionError, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Server, d.Port = "127.0.0.1", port
+			defaults := map[*string]string{&d.RecordType: "A", &d.Match: "any", &d.Transport: "udp", &d.Recursion: "true", &d.Rcode: "NOERROR", &d.DNSSEC: "false"}
+			for setting, value := range defaults {
+				if *setting == "" {
+					*setting = value
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if c.answers != "" && r.Details["answers"] != c.answers {
+				t.Errorf("answers = %q, want %q", r.Details["answers"], c.answers)
+			}
+		})
+	}
+}
+
+// A zone holds a DNSSEC signing key for a zone.
+type zone struct {
+	key    *dns.DNSKEY
+	signer crypto.Signer
+}
+
+func newZone(t *testing.T, name string) *zone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 300},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &zone{key: key, signer: priv.(crypto.Signer)}
+}
+
+// ds returns the DS record for the zone's key.
+func (z *zone) ds() string {
+	return z.key.ToDS(dns.SHA256).String()
+}
+
+// sign returns a set of records along with an RRSIG over them made with the
+// zone's key.
+func (z *zone) sign(t *testing.T, records ...string) []string {
+	var rrs []dns.RR
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	now := time.Now()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrs[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 300},
+		Algorithm:  z.key.Algorithm,
+		Expiration: uint32(now.Add(time.Hour).Unix()),
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.key.Hdr.Name,
+	}
+	err := sig.Sign(z.signer, rrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(records, sig.String())
+}
+
+func TestDNSSEC(t *testing.T) {
+	local, team01, team02 := newZone(t, "local."), newZone(t, "team01.local."), newZone(t, "team02.local.")
+	impostor := newZone(t, "team02.local.")
+
+	// team02.local's DNSKEY and records are signed consistently, but the DS
+	// record in local is for a different key
+	var records []string
+	records = append(records, local.sign(t, local.key.String())...)
+	records = append(records, local.sign(t, team01.ds())...)
+	records = append(records, local.sign(t, impostor.ds())...)
+	records = append(records, team01.sign(t, team01.key.String())...)
+	records = append(records, team01.sign(t, "web.team01.local. 300 IN A 10.0.1.10")...)
+	records = append(records, team02.sign(t, team02.key.String())...)
+	records = append(records, team02.sign(t, "web.team02.local. 300 IN A 10.0.2.10")...)
+	records = append(records, "web.team03.local. 300 IN A 10.0.3.10")
+	port := serve(t, records...)
+
+	cases := []struct {
+		name    string
+		fqdn    string
+		dnssec  string
+		anchors []string
+		passed  bool
+		failure check.Failure
+	}{
+		{"Signed", "web.team01.local", "true", nil, true, check.None},
+		{"Unsigned", "web.team03.local", "true", nil, false, check.Protocol},
+		{"NoTrustAnchor", "web.team02.local", "true", nil, true, check.None},
+		{"ZoneAnchor", "web.team01.local", "true", []string{team01.ds()}, true, check.None},
+		{"ParentAnchor", "web.team01.local", "true", []string{local.ds()}, true, check.None},
+		{"UnrelatedAnchor", "web.team01.local", "true", []string{team02.ds()}, false, check.Protocol},
+		{"BrokenChain", "web.team02.local", "true", []string{local.ds()}, false, check.Protocol},
+		{"InvalidAnchor", "web.team01.local", "true", []string{"web.team01.local. 300 IN A 10.0.1.10"}, false, check.DefinitionError},
+		{"AnchorWithoutDNSSEC", "web.team01.local", "false", []string{local.ds()}, false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Definition{
+				Server:       "127.0.0.1",
+				Port:         port,
+				Fqdn:         c.fqdn,
+				RecordType:   "A",
+				Match:        "any",
+				Transport:    "udp",
+				Recursion:    "true",
+				Rcode:        "NOERROR",
+				DNSSEC:       c.dnssec,
+				TrustAnchors: c.anchors,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+		})
+	}
+}
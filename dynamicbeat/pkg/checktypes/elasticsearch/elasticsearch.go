@@ -0,0 +1,235 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "elasticsearch",
+		Description: "Write and read back a document in Elasticsearch, or search for existing documents",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// maxResponse is the most data that will be read from a response.
+const maxResponse = 10 * 1024 * 1024
+
+// The Definition configures the behavior of the Elasticsearch check
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Host         string       `optiontype:"required"`                      // IP or hostname of the Elasticsearch node
+	Port         string       `optiontype:"optional" optiondefault:"9200"` // Port the node is listening on
+	TLS          string       `optiontype:"optional"`                      // Whether to use HTTPS
+	Verify       string       `optiontype:"optional"`                      // Whether HTTPS certificates should be validated
+	Username     string       `optiontype:"optional"`                      // Username for basic authentication
+	Password     string       `optiontype:"optional"`                      // Password for the user
+	APIKey       string       `optiontype:"optional"`                      // Base64-encoded API key, used instead of the username and password
+	Index        string       `optiontype:"required"`                      // Name of the index to use
+	Query        string       `optiontype:"optional"`                      // Search request body for finding existing documents, instead of writing a random document
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"`   // Regex that the source of at least one hit must match
+}
+
+// A statusError is returned when Elasticsearch responds with an error.
+type statusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (s statusError) Error() string {
+	return fmt.Sprintf("%s: %s", s.status, s.body)
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	useTLS, _ := strconv.ParseBool(d.TLS)
+	verify, _ := strconv.ParseBool(d.Verify)
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	// Configure the client
+	timer := &check.DialTimer{}
+	transport := &http.Transport{
+		DialContext:     timer.DialContext,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: !verify}, //nolint:gosec
+	}
+	defer transport.CloseIdleConnections()
+	client, err := es.NewClient(es.Config{
+		Addresses:    []string{fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(d.Host, d.Port))},
+		Username:     d.Username,
+		Password:     d.Password,
+		APIKey:       d.APIKey,
+		Transport:    transport,
+		DisableRetry: true,
+	})
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Creating Elasticsearch client failed : %s", err)
+		return result
+	}
+
+	// Connect and authenticate
+	start := time.Now()
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	resp, err := client.Info(client.Info.WithContext(ctx))
+	err = decode(resp, err, &info)
+	if err != nil {
+		result.Failure = failure(err)
+		result.Message = fmt.Sprintf("Failed to get cluster info : %s", err)
+		return result
+	}
+	result.Record("connect", timer.Took())
+	result.Record("auth", time.Since(start)-timer.Took())
+	result.Details = map[string]string{"version": info.Version.Number}
+
+	start = time.Now()
+	if d.Query != "" {
+		// Search for existing documents, and make sure one of them matches
+		var search struct {
+			Hits struct {
+				Hits []struct {
+					Source json.RawMessage `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		resp, err = client.Search(client.Search.WithContext(ctx), client.Search.WithIndex(d.Index), client.Search.WithBody(strings.NewReader(d.Query)))
+		err = decode(resp, err, &search)
+		if err != nil {
+			result.Failure = failure(err)
+			result.Message = fmt.Sprintf("Failed to search index %s : %s", d.Index, err)
+			return result
+		}
+
+		matched := 0
+		for _, hit := range search.Hits.Hits {
+			if regex.Match(hit.Source) {
+				matched++
+			}
+		}
+		result.Details["hits"] = strconv.Itoa(len(search.Hits.Hits))
+		result.Details["matched_hits"] = strconv.Itoa(matched)
+		if matched == 0 {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("No hits matched %s", d.ContentRegex)
+			return result
+		}
+	} else {
+		// Write a random document, and make sure it can be read back. Getting
+		// a document by ID doesn't wait for the index to refresh.
+		token := check.Token()
+		resp, err = client.Index(d.Index, strings.NewReader(fmt.Sprintf(`{"value":%q}`, token)), client.Index.WithContext(ctx), client.Index.WithDocumentID(token))
+		err = decode(resp, err, nil)
+		if err != nil {
+			result.Failure = failure(err)
+			result.Message = fmt.Sprintf("Failed to index document : %s", err)
+			return result
+		}
+		var doc struct {
+			Source struct {
+				Value string `json:"value"`
+			} `json:"_source"`
+		}
+		resp, err = client.Get(d.Index, token, client.Get.WithContext(ctx))
+		err = decode(resp, err, &doc)
+		if err != nil {
+			result.Failure = failure(err)
+			result.Message = fmt.Sprintf("Failed to get document : %s", err)
+			return result
+		}
+		if doc.Source.Value != token {
+			result.Failure = check.ContentMismatch
+			result.Message = "Document read back from Elasticsearch did not match the document written"
+			return result
+		}
+		resp, err = client.Delete(d.Index, token, client.Delete.WithContext(ctx))
+		err = decode(resp, err, nil)
+		if err != nil {
+			result.Failure = failure(err)
+			result.Message = fmt.Sprintf("Failed to delete document : %s", err)
+			return result
+		}
+	}
+	result.Time("query", start)
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// decode reads a response into v, or returns an error if the request failed.
+// The response is always closed, and if v is nil the body is discarded.
+func decode(resp *esapi.Response, err error, v interface{}) error {
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return statusError{code: resp.StatusCode, status: resp.Status(), body: strings.TrimSpace(string(body))}
+	}
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// failure classifies an error from a request.
+func failure(err error) check.Failure {
+	var status statusError
+	if errors.As(err, &status) {
+		if status.code == http.StatusUnauthorized || status.code == http.StatusForbidden {
+			return check.Auth
+		}
+		return check.Protocol
+	}
+
+	return check.Classify(err, check.Protocol)
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
@@ -0,0 +1,127 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// serve starts a stand-in Elasticsearch node that keeps documents in memory
+// and requires basic authentication as admin. Searches return every document
+// in the index.
+func serve(t *testing.T, docs map[string]map[string]string) string {
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "changeme" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"type":"security_exception","reason":"unable to authenticate user"},"status":401}`)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `{"name":"node-1","cluster_name":"scorestack","version":{"number":"7.17.10"},"tagline":"You Know, for Search"}`)
+		case len(path) == 2 && path[1] == "_search":
+			hits := []string{}
+			for _, doc := range docs[path[0]] {
+				hits = append(hits, fmt.Sprintf(`{"_index":%q,"_source":%s}`, path[0], doc))
+			}
+			fmt.Fprintf(w, `{"hits":{"total":{"value":%d},"hits":[%s]}}`, len(hits), strings.Join(hits, ","))
+		case len(path) == 3 && path[1] == "_doc":
+			index, id := path[0], path[2]
+			doc, exists := docs[index][id]
+			switch r.Method {
+			case http.MethodPut, http.MethodPost:
+				body, _ := io.ReadAll(r.Body)
+				if !json.Valid(body) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if docs[index] == nil {
+					docs[index] = make(map[string]string)
+				}
+				docs[index][id] = string(body)
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"_index":%q,"_id":%q,"result":"created"}`, index, id)
+			case http.MethodGet:
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprintf(w, `{"_index":%q,"_id":%q,"found":false}`, index, id)
+					return
+				}
+				fmt.Fprintf(w, `{"_index":%q,"_id":%q,"found":true,"_source":%s}`, index, id, doc)
+			case http.MethodDelete:
+				if !exists {
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprintf(w, `{"_index":%q,"_id":%q,"result":"not_found"}`, index, id)
+					return
+				}
+				delete(docs[index], id)
+				fmt.Fprintf(w, `{"_index":%q,"_id":%q,"result":"deleted"}`, index, id)
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	return u.Port()
+}
+
+func TestRun(t *testing.T) {
+	docs := map[string]map[string]string{"orders": {"1": `{"item":"widget","status":"shipped"}`}}
+	port := serve(t, docs)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"RoundTrip", Definition{Username: "admin", Password: "changeme", Index: "scorestack"}, true, check.None},
+		{"Query", Definition{Username: "admin", Password: "changeme", Index: "orders", Query: `{"query":{"match_all":{}}}`, ContentRegex: `"status":"shipped"`}, true, check.None},
+		{"NoMatch", Definition{Username: "admin", Password: "changeme", Index: "orders", Query: `{"query":{"match_all":{}}}`, ContentRegex: `"status":"cancelled"`}, false, check.ContentMismatch},
+		{"NoHits", Definition{Username: "admin", Password: "changeme", Index: "customers", Query: `{"query":{"match_all":{}}}`}, false, check.ContentMismatch},
+		{"WrongPassword", Definition{Username: "admin", Password: "password", Index: "scorestack"}, false, check.Auth},
+		{"NoCredentials", Definition{Index: "scorestack"}, false, check.Auth},
+		{"Regex", Definition{Username: "admin", Password: "changeme", Index: "scorestack", ContentRegex: "("}, false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host, d.Port = "127.0.0.1", port
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Passed && r.Details["version"] != "7.17.10" {
+				t.Errorf("version = %q, want %q", r.Details["version"], "7.17.10")
+			}
+		})
+	}
+
+	if len(docs["scorestack"]) != 0 {
+		t.Errorf("the check left %d documents behind", len(docs["scorestack"]))
+	}
+}
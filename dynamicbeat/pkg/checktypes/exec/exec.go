@@ -0,0 +1,298 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "exec",
+		Description: "Run a local command or script",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// maxOutput is the most output that will be kept from each of the command's
+// stdout and stderr streams. Anything past this is discarded.
+const maxOutput = 64 * 1024
+
+// maxDetail is the most output from each stream that will be included in the
+// check result's details.
+const maxDetail = 4 * 1024
+
+// inherited lists the environment variables that commands are given from
+// Dynamicbeat's own environment. Nothing else is passed through, since
+// Dynamicbeat's environment may contain its Elasticsearch credentials.
+var inherited = []string{"PATH", "SYSTEMROOT", "TEMP", "TMP", "TMPDIR"}
+
+// dir is the exec_dir setting, and env is the environment every command is
+// given. Both are set once at startup by Configure.
+var (
+	dir string
+	env []string
+)
+
+// Configure sets the directory that commands are run from, and the extra
+// environment variables they are given on top of the inherited ones. Each
+// extra variable is either NAME=value, or just NAME to copy it from
+// Dynamicbeat's environment.
+func Configure(execDir string, extra []string) {
+	dir = execDir
+	env = nil
+	for _, name := range inherited {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	for _, variable := range extra {
+		if strings.Contains(variable, "=") {
+			env = append(env, variable)
+		} else if value, ok := os.LookupEnv(variable); ok {
+			env = append(env, variable+"="+value)
+		}
+	}
+}
+
+// The Definition configures the behavior of the exec check
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Command      string       `optiontype:"required"`                          // Path to the command to run, relative to the exec_dir setting
+	Args         []string     `optiontype:"optional"`                          // Arguments to pass to the command
+	Input        string       `optiontype:"optional" optiondefault:"env"`      // How to pass the attributes to the command: env, json, or none
+	Output       string       `optiontype:"optional" optiondefault:"exitcode"` // How to decide if the check passed: exitcode, regex, or json
+	ExitCode     int          `optiontype:"optional"`                          // The exit code the command must return in exitcode mode
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"`       // Regex the command's stdout must match in regex mode
+}
+
+// A Document is the result that a command writes to stdout in json mode.
+type Document struct {
+	Passed  bool              `json:"passed"`
+	Failure string            `json:"failure"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details"`
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	// Only commands within the exec directory may be run
+	path, err := resolve(dir, d.Command)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Could not find command : %s", err)
+		return result
+	}
+
+	// The command is killed if it's still running at the check's deadline
+	cmd := exec.CommandContext(ctx, path, d.Args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.WaitDelay = time.Second
+	cmd.Env = append([]string{}, env...)
+	isolate(cmd)
+
+	// Pass the check's attributes to the command
+	switch d.Input {
+	case "env":
+		cmd.Env = append(cmd.Env, d.environment()...)
+	case "json":
+		stdin, err := d.document()
+		if err != nil {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Could not encode attributes : %s", err)
+			return result
+		}
+		cmd.Stdin = bytes.NewReader(stdin)
+	case "none":
+	default:
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid input mode '%s' : must be one of env, json, or none", d.Input)
+		return result
+	}
+
+	// Run the command
+	stdout := &limitedBuffer{max: maxOutput}
+	stderr := &limitedBuffer{max: maxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		result.Failure = check.Timeout
+		result.Message = "Command did not finish before the check's deadline"
+		return result
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Could not run command : %s", err)
+		return result
+	}
+
+	code := cmd.ProcessState.ExitCode()
+	result.Details = map[string]string{
+		"exit_code": strconv.Itoa(code),
+		"stderr":    truncate(stderr.String()),
+	}
+
+	// Decide whether the check passed
+	switch d.Output {
+	case "exitcode":
+		result.Details["stdout"] = truncate(stdout.String())
+		if code != d.ExitCode {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Command exited with code %d, expected %d", code, d.ExitCode)
+			return result
+		}
+	case "regex":
+		result.Details["stdout"] = truncate(stdout.String())
+		regex, err := regexp.Compile(d.ContentRegex)
+		if err != nil {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+			return result
+		}
+		matches := regex.FindStringSubmatch(stdout.String())
+		if matches == nil {
+			result.Failure = check.ContentMismatch
+			result.Message = "Command output did not match regex"
+			return result
+		}
+		result.Details["matched_content"] = truncate(matches[len(matches)-1])
+	case "json":
+		var doc Document
+		err = json.Unmarshal(stdout.Bytes(), &doc)
+		if err != nil {
+			result.Details["stdout"] = truncate(stdout.String())
+			result.Failure = check.Protocol
+			result.Message = fmt.Sprintf("Could not decode command output as a result document : %s", err)
+			return result
+		}
+
+		// The result document maps directly onto the check result
+		for k, v := range doc.Details {
+			result.Details[k] = v
+		}
+		result.Message = doc.Message
+		if !doc.Passed {
+			result.Failure = check.Failure(doc.Failure)
+			switch {
+			case result.Failure == check.None:
+				result.Failure = check.ContentMismatch
+			case !result.Failure.Known():
+				result.Failure = check.Protocol
+				result.Message = fmt.Sprintf("Command reported an unknown failure category '%s' : %s", doc.Failure, doc.Message)
+			}
+			return result
+		}
+	default:
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid output mode '%s' : must be one of exitcode, regex, or json", d.Output)
+		return result
+	}
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// resolve finds the full path to a command within the exec directory.
+func resolve(dir string, command string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("exec checks are disabled because exec_dir is not set")
+	}
+
+	return files.Resolve(dir, command)
+}
+
+// environment converts the check's metadata and attributes into environment
+// variables. Attribute names are uppercased and any characters that aren't
+// letters, numbers, or underscores are replaced with underscores.
+func (d *Definition) environment() []string {
+	env := []string{
+		fmt.Sprintf("SCORESTACK_CHECK_ID=%s", d.Config.ID),
+		fmt.Sprintf("SCORESTACK_CHECK_NAME=%s", d.Config.Name),
+		fmt.Sprintf("SCORESTACK_CHECK_GROUP=%s", d.Config.Group),
+	}
+
+	attributes := d.Config.Attributes.Merged()
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	invalid := regexp.MustCompile(`[^A-Z0-9_]`)
+	for _, name := range names {
+		key := invalid.ReplaceAllString(strings.ToUpper(name), "_")
+		env = append(env, fmt.Sprintf("SCORESTACK_ATTR_%s=%s", key, attributes[name]))
+	}
+
+	return env
+}
+
+// document encodes the check's metadata and attributes as JSON.
+func (d *Definition) document() ([]byte, error) {
+	return json.Marshal(struct {
+		ID         string            `json:"id"`
+		Name       string            `json:"name"`
+		Group      string            `json:"group"`
+		Attributes map[string]string `json:"attributes"`
+	}{d.Config.ID, d.Config.Name, d.Config.Group, d.Config.Attributes.Merged()})
+}
+
+func truncate(s string) string {
+	if len(s) <= maxDetail {
+		return s
+	}
+
+	return s[:maxDetail] + "..."
+}
+
+// A limitedBuffer keeps the first max bytes written to it and silently
+// discards the rest, so that a noisy command can't exhaust Dynamicbeat's
+// memory or fail because its output was closed.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
@@ -0,0 +1,115 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestConfigure(t *testing.T) {
+	t.Setenv("TMPDIR", "/tmp")
+	t.Setenv("ELASTICSEARCH_PASSWORD", "secret")
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+	t.Setenv("LANG", "C.UTF-8")
+	defer Configure("", nil)
+
+	Configure("/opt/checks", []string{"HTTPS_PROXY", "SERVICE=web", "UNSET_VARIABLE"})
+	if dir != "/opt/checks" {
+		t.Errorf("dir = %s, want /opt/checks", dir)
+	}
+
+	got := make(map[string]bool, len(env))
+	for _, variable := range env {
+		got[variable] = true
+	}
+	for _, want := range []string{"TMPDIR=/tmp", "HTTPS_PROXY=http://proxy:3128", "SERVICE=web"} {
+		if !got[want] {
+			t.Errorf("environment is missing %s: %v", want, env)
+		}
+	}
+	for _, unwanted := range []string{"ELASTICSEARCH_PASSWORD=secret", "LANG=C.UTF-8", "UNSET_VARIABLE="} {
+		if got[unwanted] {
+			t.Errorf("environment includes %s", unwanted)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands are shell scripts")
+	}
+
+	execDir := t.TempDir()
+	scripts := map[string]string{
+		"env.sh":   "#!/bin/sh\necho \"$SCORESTACK_CHECK_ID $SCORESTACK_ATTR_WEB_PORT ${ELASTICSEARCH_PASSWORD:-unset}\"\n",
+		"exit.sh":  "#!/bin/sh\nexit 3\n",
+		"json.sh":  "#!/bin/sh\ncat > /dev/null\necho '{\"passed\": false, \"failure\": \"auth\", \"message\": \"login failed\", \"details\": {\"user\": \"admin\"}}'\n",
+		"stdin.sh": "#!/bin/sh\ncat\n",
+		"odd.sh":   "#!/bin/sh\necho '{\"passed\": false, \"failure\": \"broken\", \"message\": \"login failed\"}'\n",
+		"sleep.sh": "#!/bin/sh\nexec sleep 10\n",
+	}
+	for name, script := range scripts {
+		err := os.WriteFile(filepath.Join(execDir, name), []byte(script), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("ELASTICSEARCH_PASSWORD", "secret")
+	Configure(execDir, nil)
+	defer Configure("", nil)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		detail  string // the expected value of the stdout detail, if any
+	}{
+		{"Env", Definition{Command: "env.sh", Output: "regex", ContentRegex: `(?m)^exec-team01 8080 unset$`}, true, check.None, "exec-team01 8080 unset\n"},
+		{"ExitCode", Definition{Command: "exit.sh", Output: "exitcode", ExitCode: 3}, true, check.None, ""},
+		{"WrongExitCode", Definition{Command: "exit.sh", Output: "exitcode"}, false, check.ContentMismatch, ""},
+		{"JSON", Definition{Command: "json.sh", Input: "json", Output: "json"}, false, check.Auth, ""},
+		{"UnknownFailure", Definition{Command: "odd.sh", Output: "json"}, false, check.Protocol, ""},
+		{"Stdin", Definition{Command: "stdin.sh", Input: "json", Output: "regex", ContentRegex: `"group":"team01"`}, true, check.None, ""},
+		{"Escape", Definition{Command: "../../bin/sh", Output: "exitcode"}, false, check.DefinitionError, ""},
+		{"Missing", Definition{Command: "missing.sh", Output: "exitcode"}, false, check.DefinitionError, ""},
+		{"InputMode", Definition{Command: "exit.sh", Input: "args", Output: "exitcode"}, false, check.DefinitionError, ""},
+		{"OutputMode", Definition{Command: "exit.sh", Output: "stdout"}, false, check.DefinitionError, ""},
+		{"Deadline", Definition{Command: "sleep.sh", Output: "exitcode"}, false, check.Timeout, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			if d.Input == "" {
+				d.Input = "env"
+			}
+			d.Config = check.Config{
+				Metadata:   check.Metadata{ID: "exec-team01", Group: "team01"},
+				Attributes: check.Attributes{User: map[string]string{"web-port": "8080"}},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if c.detail != "" && r.Details["stdout"] != c.detail {
+				t.Errorf("stdout = %q, want %q", r.Details["stdout"], c.detail)
+			}
+		})
+	}
+}
+
+func TestResolveDisabled(t *testing.T) {
+	_, err := resolve("", "check.sh")
+	if err == nil {
+		t.Error("resolve() allowed a command without exec_dir set")
+	}
+}
@@ -0,0 +1,349 @@
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// maxFile is the most data that will be read from a file or listing.
+const maxFile = 10 * 1024 * 1024
+
+// A client is a minimal FTP client. FTP libraries for Go only support passive
+// mode, so the check speaks the protocol itself.
+type client struct {
+	ctx       context.Context
+	conn      net.Conn        // the control connection
+	text      *textproto.Conn // reads replies from and writes commands to the control connection
+	tlsConfig *tls.Config     // TLS configuration for data connections, or nil if they are unencrypted
+	active    bool            // whether the server opens data connections to the client
+	banner    string          // the server's greeting
+}
+
+// connect opens the control connection and reads the server's greeting. If
+// tlsConfig is set, the control connection is encrypted right away for
+// implicit TLS, or after sending AUTH TLS for explicit TLS, and data
+// connections are encrypted too.
+func connect(ctx context.Context, addr string, tlsConfig *tls.Config, implicit bool, active bool) (*client, error) {
+	conn, err := check.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{ctx: ctx, conn: conn, active: active}
+	if tlsConfig != nil && implicit {
+		c.conn = tls.Client(conn, tlsConfig)
+	}
+	c.text = textproto.NewConn(c.conn)
+
+	_, c.banner, err = c.text.ReadResponse(220)
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return c, nil
+	}
+
+	if !implicit {
+		_, err = c.cmd(234, "AUTH TLS")
+		if err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("server refused AUTH TLS: %w", err)
+		}
+		c.conn = tls.Client(conn, tlsConfig)
+		c.text = textproto.NewConn(c.conn)
+	}
+
+	// Protect data connections as well
+	for _, command := range []string{"PBSZ 0", "PROT P"} {
+		_, err = c.cmd(200, command)
+		if err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+	c.tlsConfig = tlsConfig
+
+	return c, nil
+}
+
+// login authenticates with the server and switches to binary transfers.
+func (c *client) login(username string, password string) error {
+	code, msg, err := c.send("USER " + username)
+	if err != nil {
+		return err
+	}
+	switch {
+	case code == 331:
+		_, err = c.cmd(2, "PASS "+password)
+		if err != nil {
+			return err
+		}
+	case code/100 != 2:
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+
+	_, err = c.cmd(200, "TYPE I")
+	return err
+}
+
+// cwd changes the current directory.
+func (c *client) cwd(dir string) error {
+	_, err := c.cmd(250, "CWD "+dir)
+	return err
+}
+
+// pwd returns the current directory.
+func (c *client) pwd() (string, error) {
+	return c.cmd(257, "PWD")
+}
+
+// retr downloads a file.
+func (c *client) retr(name string) ([]byte, error) {
+	return c.download("RETR " + name)
+}
+
+// stor uploads a file.
+func (c *client) stor(name string, data []byte) error {
+	conn, err := c.transfer("STOR " + name)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(data)
+	closeErr := conn.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.text.ReadResponse(2)
+	return err
+}
+
+// dele deletes a file.
+func (c *client) dele(name string) error {
+	_, err := c.cmd(250, "DELE "+name)
+	return err
+}
+
+// nlst returns the names of the entries in a directory.
+func (c *client) nlst(dir string) ([]string, error) {
+	data, err := c.download("NLST " + dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// Some servers include the directory in each name
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			names = append(names, path.Base(line))
+		}
+	}
+
+	return names, nil
+}
+
+// quit ends the session and closes the control connection.
+func (c *client) quit() error {
+	_, err := c.cmd(221, "QUIT")
+	closeErr := c.conn.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// download runs a command that sends data to the client and returns the data.
+func (c *client) download(command string) ([]byte, error) {
+	conn, err := c.transfer(command)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, maxFile))
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = c.text.ReadResponse(2)
+	return data, err
+}
+
+// transfer opens a data connection and sends a command that uses it.
+func (c *client) transfer(command string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if c.active {
+		conn, err = c.activeConn(command)
+	} else {
+		conn, err = c.passiveConn(command)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The client is always the TLS client, even when the server opened the
+	// connection
+	if c.tlsConfig != nil {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+
+	return conn, nil
+}
+
+// passiveConn connects to the port the server opens for the data connection,
+// then sends the command.
+func (c *client) passiveConn(command string) (net.Conn, error) {
+	port, err := c.epsv()
+	if err != nil {
+		port, err = c.pasv()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Servers behind NAT often reply to PASV with their private address, so
+	// the data connection always goes to the host the control connection uses
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := check.Dial(c.ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("could not open data connection: %w", err)
+	}
+
+	_, err = c.cmd(1, command)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// epsv asks the server for an extended passive mode port.
+func (c *client) epsv() (int, error) {
+	msg, err := c.cmd(229, "EPSV")
+	if err != nil {
+		return 0, err
+	}
+
+	// The reply looks like: Entering Extended Passive Mode (|||6446|)
+	start := strings.Index(msg, "|||")
+	end := strings.LastIndex(msg, "|")
+	if start < 0 || end <= start+3 {
+		return 0, fmt.Errorf("invalid EPSV reply: %s", msg)
+	}
+
+	return strconv.Atoi(msg[start+3 : end])
+}
+
+// pasv asks the server for a passive mode port.
+func (c *client) pasv() (int, error) {
+	msg, err := c.cmd(227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+
+	// The reply looks like: Entering Passive Mode (h1,h2,h3,h4,p1,p2)
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+	if start < 0 || end <= start {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+
+	return p1<<8 | p2, nil
+}
+
+// activeConn listens for the server to open the data connection, tells the
+// server where to connect, then sends the command and accepts the connection.
+func (c *client) activeConn(command string) (net.Conn, error) {
+	// Listen on the address the control connection uses, since the server
+	// can already reach it
+	host, _, err := net.SplitHostPort(c.conn.LocalAddr().String())
+	if err != nil {
+		return nil, err
+	}
+	l, err := (&net.ListenConfig{}).Listen(c.ctx, "tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("could not listen for data connection: %w", err)
+	}
+	defer l.Close()
+
+	addr := l.Addr().(*net.TCPAddr)
+	if ip := addr.IP.To4(); ip != nil {
+		_, err = c.cmd(200, fmt.Sprintf("PORT %d,%d,%d,%d,%d,%d", ip[0], ip[1], ip[2], ip[3], addr.Port>>8, addr.Port&0xff))
+	} else {
+		_, err = c.cmd(200, fmt.Sprintf("EPRT |2|%s|%d|", addr.IP, addr.Port))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.cmd(1, command)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.(*net.TCPListener).SetDeadline(check.Deadline(c.ctx))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("server did not open data connection: %w", err)
+	}
+	err = conn.SetDeadline(check.Deadline(c.ctx))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// cmd sends a command and makes sure the reply has the expected code. Codes
+// with fewer than three digits match any reply code that starts with them.
+func (c *client) cmd(expect int, command string) (string, error) {
+	err := c.text.PrintfLine("%s", command)
+	if err != nil {
+		return "", err
+	}
+
+	_, msg, err := c.text.ReadResponse(expect)
+	return msg, err
+}
+
+// send sends a command and returns the reply, whatever its code is.
+func (c *client) send(command string) (int, string, error) {
+	err := c.text.PrintfLine("%s", command)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return c.text.ReadResponse(0)
+}
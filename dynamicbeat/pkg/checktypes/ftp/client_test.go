@@ -0,0 +1,484 @@
+package ftp
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A server is a stand-in FTP server that keeps files in memory. Names are
+// relative to the root, like "pub/motd.txt".
+type server struct {
+	password string            // the password for any user
+	files    map[string]string // the files on the server
+	tls      *tls.Config       // the TLS configuration, or nil if TLS isn't supported
+	implicit bool              // whether connections are encrypted right away
+	noEPSV   bool              // whether EPSV is refused, so clients must fall back to PASV
+
+	mu sync.Mutex
+}
+
+// certificate returns a TLS configuration with a self-signed certificate for
+// the stand-in server. TLS 1.3 is disabled so that clients don't hang up on
+// session tickets sent after the handshake while uploading.
+func certificate(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: tls.VersionTLS12}
+}
+
+// serve starts the stand-in server and returns its port.
+func (s *server) serve(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// handle runs a single FTP session.
+func (s *server) handle(raw net.Conn) {
+	conn := raw
+	defer func() { conn.Close() }()
+	if s.implicit {
+		conn = tls.Server(raw, s.tls)
+	}
+	text := textproto.NewConn(conn)
+
+	var (
+		dir       = "/"
+		protected bool
+		passive   net.Listener
+		port      string
+	)
+	defer func() {
+		if passive != nil {
+			passive.Close()
+		}
+	}()
+
+	// data opens the data connection set up by the last EPSV, PASV, or PORT
+	data := func() (net.Conn, error) {
+		var c net.Conn
+		var err error
+		if passive != nil {
+			c, err = passive.Accept()
+			passive.Close()
+			passive = nil
+		} else {
+			c, err = net.Dial("tcp", port)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if protected {
+			c = tls.Server(c, s.tls)
+		}
+		return c, nil
+	}
+
+	reply := func(code int, msg string) bool {
+		return text.PrintfLine("%d %s", code, msg) == nil
+	}
+	if !reply(220, "Stand-in FTP server ready") {
+		return
+	}
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		name := strings.TrimPrefix(arg, "/")
+
+		s.mu.Lock()
+		content, exists := s.files[name]
+		s.mu.Unlock()
+
+		var ok bool
+		switch strings.ToUpper(command) {
+		case "AUTH":
+			if s.tls == nil || s.implicit {
+				ok = reply(502, "TLS not supported")
+				break
+			}
+			ok = reply(234, "Proceed with negotiation")
+			conn = tls.Server(raw, s.tls)
+			text = textproto.NewConn(conn)
+		case "PBSZ":
+			ok = reply(200, "PBSZ=0")
+		case "PROT":
+			protected = arg == "P"
+			ok = reply(200, "Protection level set")
+		case "USER":
+			ok = reply(331, "Password required")
+		case "PASS":
+			if arg != s.password {
+				ok = reply(530, "Login incorrect")
+				break
+			}
+			ok = reply(230, "Login successful")
+		case "TYPE":
+			ok = reply(200, "Switching to binary mode")
+		case "CWD":
+			found := false
+			s.mu.Lock()
+			for f := range s.files {
+				found = found || strings.HasPrefix(f, name+"/")
+			}
+			s.mu.Unlock()
+			if !found {
+				ok = reply(550, "Failed to change directory")
+				break
+			}
+			dir = "/" + name
+			ok = reply(250, "Directory successfully changed")
+		case "PWD":
+			ok = reply(257, fmt.Sprintf("%q is the current directory", dir))
+		case "EPSV", "PASV":
+			if command == "EPSV" && s.noEPSV {
+				ok = reply(500, "Unknown command")
+				break
+			}
+			passive, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				ok = reply(425, "Can't open passive connection")
+				break
+			}
+			p := passive.Addr().(*net.TCPAddr).Port
+			if command == "EPSV" {
+				ok = reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", p))
+				break
+			}
+			// Reply with a private address, like a server behind NAT
+			ok = reply(227, fmt.Sprintf("Entering Passive Mode (10,0,0,21,%d,%d)", p>>8, p&0xff))
+		case "PORT":
+			fields := strings.Split(arg, ",")
+			if len(fields) != 6 {
+				ok = reply(501, "Illegal PORT command")
+				break
+			}
+			p1, _ := strconv.Atoi(fields[4])
+			p2, _ := strconv.Atoi(fields[5])
+			port = net.JoinHostPort(strings.Join(fields[:4], "."), strconv.Itoa(p1<<8|p2))
+			ok = reply(200, "PORT command successful")
+		case "RETR", "NLST":
+			var listing []string
+			if command == "NLST" {
+				s.mu.Lock()
+				for f := range s.files {
+					if strings.HasPrefix(f, name+"/") {
+						listing = append(listing, f)
+					}
+				}
+				s.mu.Unlock()
+				sort.Strings(listing)
+				content, exists = strings.Join(listing, "\r\n")+"\r\n", len(listing) > 0
+			}
+			if !exists {
+				if passive != nil {
+					passive.Close()
+					passive = nil
+				}
+				ok = reply(550, "Failed to open file")
+				break
+			}
+			if !reply(150, "Opening BINARY mode data connection") {
+				return
+			}
+			c, err := data()
+			if err != nil {
+				ok = reply(425, "Failed to establish connection")
+				break
+			}
+			_, err = io.WriteString(c, content)
+			c.Close()
+			if err != nil {
+				ok = reply(426, "Failure writing network stream")
+				break
+			}
+			ok = reply(226, "Transfer complete")
+		case "STOR":
+			if !reply(150, "Ok to send data") {
+				return
+			}
+			c, err := data()
+			if err != nil {
+				ok = reply(425, "Failed to establish connection")
+				break
+			}
+			uploaded, err := io.ReadAll(c)
+			c.Close()
+			if err != nil {
+				ok = reply(426, "Failure reading network stream")
+				break
+			}
+			s.mu.Lock()
+			s.files[name] = string(uploaded)
+			s.mu.Unlock()
+			ok = reply(226, "Transfer complete")
+		case "DELE":
+			if !exists {
+				ok = reply(550, "Delete operation failed")
+				break
+			}
+			s.mu.Lock()
+			delete(s.files, name)
+			s.mu.Unlock()
+			ok = reply(250, "Delete operation successful")
+		case "QUIT":
+			reply(221, "Goodbye")
+			return
+		default:
+			ok = reply(502, "Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	config := certificate(t)
+
+	cases := []struct {
+		name     string
+		server   *server
+		tls      bool
+		implicit bool
+		active   bool
+	}{
+		{"EPSV", &server{}, false, false, false},
+		{"PASV", &server{noEPSV: true}, false, false, false},
+		{"Active", &server{}, false, false, true},
+		{"Explicit", &server{tls: config}, true, false, false},
+		{"ExplicitActive", &server{tls: config}, true, false, true},
+		{"Implicit", &server{tls: config, implicit: true}, true, true, false},
+		{"ImplicitPASV", &server{tls: config, implicit: true, noEPSV: true}, true, true, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.server.password = "changeme"
+			c.server.files = map[string]string{"pub/motd.txt": "Welcome to Team 01\n", "pub/readme.txt": "Nothing to see here\n"}
+			port := c.server.serve(t)
+
+			var tlsConfig *tls.Config
+			if c.tls {
+				tlsConfig = &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(0)} //nolint:gosec
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := connect(ctx, net.JoinHostPort("127.0.0.1", port), tlsConfig, c.implicit, c.active)
+			if err != nil {
+				t.Fatalf("connect() error = %s", err)
+			}
+			if conn.banner != "Stand-in FTP server ready" {
+				t.Errorf("banner = %q, want %q", conn.banner, "Stand-in FTP server ready")
+			}
+			if err := conn.login("anonymous", "changeme"); err != nil {
+				t.Fatalf("login() error = %s", err)
+			}
+
+			content, err := conn.retr("pub/motd.txt")
+			if err != nil || string(content) != "Welcome to Team 01\n" {
+				t.Errorf("retr() = %q, %v, want %q", content, err, "Welcome to Team 01\n")
+			}
+			entries, err := conn.nlst("pub")
+			if want := []string{"motd.txt", "readme.txt"}; err != nil || !reflect.DeepEqual(entries, want) {
+				t.Errorf("nlst() = %q, %v, want %q", entries, err, want)
+			}
+			if err := conn.stor("pub/token.txt", []byte("token")); err != nil {
+				t.Errorf("stor() error = %s", err)
+			}
+			content, err = conn.retr("pub/token.txt")
+			if err != nil || string(content) != "token" {
+				t.Errorf("retr() = %q, %v, want %q", content, err, "token")
+			}
+			if err := conn.dele("pub/token.txt"); err != nil {
+				t.Errorf("dele() error = %s", err)
+			}
+
+			// The connection keeps working after a command is refused
+			if _, err := conn.retr("pub/token.txt"); err == nil {
+				t.Error("retr() of a deleted file succeeded")
+			}
+			if err := conn.cwd("pub"); err != nil {
+				t.Errorf("cwd() error = %s", err)
+			}
+			if dir, err := conn.pwd(); err != nil || !strings.HasPrefix(dir, `"/pub"`) {
+				t.Errorf("pwd() = %q, %v, want the /pub directory", dir, err)
+			}
+			if err := conn.quit(); err != nil {
+				t.Errorf("quit() error = %s", err)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	port := (&server{password: "changeme", files: map[string]string{}}).serve(t)
+
+	cases := []struct {
+		name     string
+		password string
+		code     int
+	}{
+		{"Correct", "changeme", 0},
+		{"Incorrect", "password", 530},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := connect(context.Background(), net.JoinHostPort("127.0.0.1", port), nil, false, false)
+			if err != nil {
+				t.Fatalf("connect() error = %s", err)
+			}
+			defer conn.quit() //nolint:errcheck
+
+			err = conn.login("admin", c.password)
+			code := 0
+			if protoErr, ok := err.(*textproto.Error); ok {
+				code = protoErr.Code
+			} else if err != nil {
+				t.Fatalf("login() error = %s", err)
+			}
+			if code != c.code {
+				t.Errorf("login() reply code = %d, want %d", code, c.code)
+			}
+		})
+	}
+}
+
+func TestExplicitRefused(t *testing.T) {
+	port := (&server{files: map[string]string{}}).serve(t)
+
+	_, err := connect(context.Background(), net.JoinHostPort("127.0.0.1", port), &tls.Config{InsecureSkipVerify: true}, false, false) //nolint:gosec
+	if err == nil || !strings.Contains(err.Error(), "server refused AUTH TLS") {
+		t.Errorf("connect() error = %v, want AUTH TLS to be refused", err)
+	}
+}
+
+// reply connects a client to a stand-in server that reads a single command,
+// sends a canned reply, and hangs up.
+func reply(t *testing.T, canned string) *client {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+
+	go func() {
+		_, err := textproto.NewReader(bufio.NewReader(serverConn)).ReadLine()
+		if err != nil {
+			return
+		}
+		_, _ = serverConn.Write([]byte(canned))
+		serverConn.Close()
+	}()
+
+	return &client{ctx: context.Background(), conn: clientConn, text: textproto.NewConn(clientConn)}
+}
+
+func TestEPSV(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		port  int
+		err   bool
+	}{
+		{"Port", "229 Entering Extended Passive Mode (|||6446|)\r\n", 6446, false},
+		{"NoParentheses", "229 |||6446|\r\n", 6446, false},
+		{"NoPort", "229 Entering Extended Passive Mode (||||)\r\n", 0, true},
+		{"InvalidPort", "229 Entering Extended Passive Mode (|||lots|)\r\n", 0, true},
+		{"Malformed", "229 Entering Extended Passive Mode\r\n", 0, true},
+		{"Refused", "500 Unknown command\r\n", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			port, err := reply(t, c.reply).epsv()
+			if (err != nil) != c.err {
+				t.Fatalf("epsv() error = %v, want error: %t", err, c.err)
+			}
+			if port != c.port {
+				t.Errorf("epsv() = %d, want %d", port, c.port)
+			}
+		})
+	}
+}
+
+func TestPASV(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		port  int
+		err   bool
+	}{
+		{"Port", "227 Entering Passive Mode (10,0,0,21,25,46)\r\n", 25<<8 | 46, false},
+		{"Spaces", "227 Entering Passive Mode (10, 0, 0, 21, 25, 46)\r\n", 25<<8 | 46, false},
+		{"TooFewFields", "227 Entering Passive Mode (10,0,0,21,25)\r\n", 0, true},
+		{"InvalidPort", "227 Entering Passive Mode (10,0,0,21,high,low)\r\n", 0, true},
+		{"NoParentheses", "227 Entering Passive Mode 10,0,0,21,25,46\r\n", 0, true},
+		{"Refused", "502 Command not implemented\r\n", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			port, err := reply(t, c.reply).pasv()
+			if (err != nil) != c.err {
+				t.Fatalf("pasv() error = %v, want error: %t", err, c.err)
+			}
+			if port != c.port {
+				t.Errorf("pasv() = %d, want %d", port, c.port)
+			}
+		})
+	}
+}
@@ -2,32 +2,48 @@ package ftp
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
-	"io"
+	"net"
 	"regexp"
 	"strconv"
 	"time"
 
-	"github.com/jlaffaye/ftp"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/sha3"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "ftp",
+		Description: "Log in to an FTP or SFTP server, optionally over TLS, and read, write, or list files",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the FTP check
 // it implements the "check" interface
 type Definition struct {
 	Config           check.Config // generic metadata about the check
-	Host             string       `optiontype:"required"`                    // IP or hostname of the host to run the FTP check against
-	Username         string       `optiontype:"required"`                    // The user to login with over FTP
-	Password         string       `optiontype:"required"`                    // The password for the user that you wish to login with
-	File             string       `optiontype:"required"`                    // The path to the file to access during the FTP check
-	ContentRegex     string       `optiontype:"optional" optiondefault:".*"` // Regex to match if reading a file
-	HashContentMatch string       `optiontype:"optional"`                    // Whether or not to match a hash of the file contents
-	Hash             string       `optiontype:"optional"`                    // The hash digest from sha3-256 to compare the hashed file contents to
-	Port             string       `optiontype:"optional" optiondefault:"21"` // The port to attempt an ftp connection on
-	Simple           string       `optiontype:"optional"`                    // Very simple FTP check for older servers
+	Protocol         string       `optiontype:"optional" optiondefault:"ftp"`     // The protocol to use: ftp or sftp
+	Host             string       `optiontype:"required"`                         // IP or hostname of the host to run the FTP check against
+	Username         string       `optiontype:"required"`                         // The user to login with over FTP
+	Password         string       `optiontype:"required"`                         // The password for the user that you wish to login with
+	File             string       `optiontype:"optional"`                         // The path to the file to access during the FTP check
+	ContentRegex     string       `optiontype:"optional" optiondefault:".*"`      // Regex to match if reading a file
+	HashContentMatch string       `optiontype:"optional"`                         // Whether or not to match a hash of the file contents
+	Hash             string       `optiontype:"optional"`                         // The hash digest from sha3-256 to compare the hashed file contents to
+	Directory        string       `optiontype:"optional"`                         // Directory to list
+	Entries          []string     `optiontype:"optional"`                         // Names that must be in the directory
+	WriteFile        string       `optiontype:"optional"`                         // File to upload a random token to, download, and delete
+	TLS              string       `optiontype:"optional"`                         // FTPS mode: explicit (AUTH TLS) or implicit
+	Verify           string       `optiontype:"optional"`                         // Whether TLS certificates should be validated
+	Mode             string       `optiontype:"optional" optiondefault:"passive"` // Data connection mode: passive or active
+	HostKey          string       `optiontype:"optional"`                         // The expected SHA256 fingerprint of the server's SSH host key, for SFTP
+	Port             string       `optiontype:"optional"`                         // The port to attempt an ftp connection on, by default 21, 990 for implicit TLS, or 22 for SFTP
+	Simple           string       `optiontype:"optional"`                         // Very simple FTP check for older servers
 }
 
 // Run a single instance of the check
@@ -35,39 +51,128 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Initialize empty result
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
-	// Connect to the ftp server
-	// TODO: create child context with deadline less than the parent context
-	conn, err := ftp.Dial(fmt.Sprintf("%s:%s", d.Host, d.Port), ftp.DialWithContext(ctx))
+	// Validate the definition before connecting
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	simple, _ := strconv.ParseBool(d.Simple)
+	useSFTP := false
+	switch d.Protocol {
+	case "", "ftp":
+	case "sftp":
+		useSFTP = true
+		if d.TLS != "" || d.Mode == "active" || simple {
+			result.Failure = check.DefinitionError
+			result.Message = "TLS, active Mode, and Simple are only supported for FTP"
+			return result
+		}
+	default:
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid Protocol '%s' : must be ftp or sftp", d.Protocol)
+		return result
+	}
+	port := d.Port
+	var tlsConfig *tls.Config
+	implicit := false
+	switch d.TLS {
+	case "":
+	case "explicit", "implicit":
+		verify, _ := strconv.ParseBool(d.Verify)
+		tlsConfig = &tls.Config{
+			ServerName:         d.Host,
+			InsecureSkipVerify: !verify, //nolint:gosec
+			// Many servers require data connections to resume the control
+			// connection's TLS session
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+		}
+		implicit = d.TLS == "implicit"
+	default:
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid TLS '%s' : must be explicit or implicit", d.TLS)
+		return result
+	}
+	if port == "" {
+		switch {
+		case useSFTP:
+			port = "22"
+		case implicit:
+			port = "990"
+		default:
+			port = "21"
+		}
+	}
+	active := false
+	switch d.Mode {
+	case "", "passive":
+	case "active":
+		active = true
+	default:
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid Mode '%s' : must be passive or active", d.Mode)
+		return result
+	}
+
+	if useSFTP {
+		// Log in over SSH and start an SFTP session. The SSH connection
+		// obeys the check's deadline.
+		conn := dialSFTP(ctx, net.JoinHostPort(d.Host, port), d.Username, d.Password, d.HostKey, &result)
+		if conn == nil {
+			return result
+		}
+		defer func() {
+			err := conn.quit()
+			if err != nil {
+				zap.S().Warnf("Failed to close SFTP connection: %s", err)
+			}
+		}()
+
+		return d.transfer(conn, regex, result)
+	}
+
+	// Connect to the ftp server. Both the control and data connections obey
+	// the check's deadline.
+	start := time.Now()
+	conn, err := connect(ctx, net.JoinHostPort(d.Host, port), tlsConfig, implicit, active)
 	if err != nil {
-		result.Message = fmt.Sprintf("Connection to %s on port %s failed : %s", d.Host, d.Port, err)
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Connection to %s on port %s failed : %s", d.Host, port, err)
 		return result
 	}
+	result.Time("connect", start)
+	result.Details = map[string]string{"banner": conn.banner}
 	defer func() {
-		err := conn.Quit()
+		err := conn.quit()
 		if err != nil {
 			zap.S().Warnf("Failed to close FTP connection: %s", err)
 		}
 	}()
 
 	// Login
-	err = conn.Login(d.Username, d.Password)
+	start = time.Now()
+	err = conn.login(d.Username, d.Password)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Login attempt with user %s failed : %s", d.Username, err)
 		return result
 	}
+	result.Time("auth", start)
 
 	// ***********************************************
-	if simple, _ := strconv.ParseBool(d.Simple); simple {
+	if simple {
 		// Do a simple FTP check for servers that don't support a lot of FTP commands
-		err = conn.ChangeDir(d.File)
+		err = conn.cwd(d.File)
 		if err != nil {
+			result.Failure = check.Classify(err, check.ContentMismatch)
 			result.Message = fmt.Sprintf("Changing to directory %s failed : %s", d.File, err)
 			return result
 		}
 
-		_, err := conn.CurrentDir()
-		// entries, err := conn.List("/")
+		_, err := conn.pwd()
 		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
 			result.Message = fmt.Sprintf("Getting current directory %s failed : %s", d.File, err)
 			return result
 		}
@@ -78,52 +183,111 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	}
 	// **************
 
-	// Retrieve file contents
-	resp, err := conn.Retr(d.File)
-	if err != nil {
-		result.Message = fmt.Sprintf("Could not retrieve file %s : %s", d.File, err)
-		return result
-	}
-	defer resp.Close()
+	return d.transfer(conn, regex, result)
+}
 
-	content, err := io.ReadAll(resp)
-	if err != nil {
-		result.Message = fmt.Sprintf("Could not read file %s contents : %s", d.File, err)
-		return result
+// transfer lists the directory, reads the file, and writes the upload file,
+// for whichever of them are configured.
+func (d *Definition) transfer(conn session, regex *regexp.Regexp, result check.Result) check.Result {
+	start := time.Now()
+	if d.Directory != "" {
+		failure, err := d.list(conn)
+		if err != nil {
+			result.Failure = failure
+			result.Message = fmt.Sprintf("Error listing directory %s : %s", d.Directory, err)
+			return result
+		}
 	}
+	if d.File != "" {
+		// Retrieve file contents
+		content, err := conn.retr(d.File)
+		if err != nil {
+			result.Failure = check.Classify(err, check.ContentMismatch)
+			result.Message = fmt.Sprintf("Could not retrieve file %s : %s", d.File, err)
+			return result
+		}
 
-	// Check if we are doing hash matching, non default
-	if matchHash, _ := strconv.ParseBool(d.HashContentMatch); matchHash {
-		// Get the file hash
-		digest := sha3.Sum256(content)
+		// Check if we are doing hash matching, non default
+		if matchHash, _ := strconv.ParseBool(d.HashContentMatch); matchHash {
+			// Get the file hash
+			digest := sha3.Sum256(content)
 
-		// Check if the digest of the file matches the defined hash
-		if digestString := hex.EncodeToString(digest[:]); digestString != d.Hash {
-			result.Message = "Incorrect hash"
+			// Check if the digest of the file matches the defined hash
+			if digestString := hex.EncodeToString(digest[:]); digestString != d.Hash {
+				result.Failure = check.ContentMismatch
+				result.Message = "Incorrect hash"
+				return result
+			}
+		} else if !regex.Match(content) {
+			// Default, regex content matching
+			result.Failure = check.ContentMismatch
+			result.Message = "Matching content not found"
 			return result
 		}
+	}
+	if d.WriteFile != "" {
+		failure, err := write(conn, d.WriteFile)
+		if err != nil {
+			result.Failure = failure
+			result.Message = fmt.Sprintf("Error verifying upload to %s : %s", d.WriteFile, err)
+			return result
+		}
+	}
+	result.Time("transfer", start)
 
-		// If we make it here the check was successful for matching hashes
-		result.Passed = true
-		return result
+	// If we reach here the check is successful
+	result.Passed = true
+	return result
+}
+
+// list makes sure the directory contains all the expected entries.
+func (d *Definition) list(conn session) (check.Failure, error) {
+	entries, err := conn.nlst(d.Directory)
+	if err != nil {
+		return check.Classify(err, check.ContentMismatch), err
 	}
 
-	// Default, regex content matching
-	regex, err := regexp.Compile(d.ContentRegex)
+	for _, expected := range d.Entries {
+		found := false
+		for _, entry := range entries {
+			if entry == expected {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return check.ContentMismatch, fmt.Errorf("%s not found", expected)
+		}
+	}
+
+	return check.None, nil
+}
+
+// write uploads a random token to a file, downloads it, and deletes the file.
+func write(conn session, name string) (check.Failure, error) {
+	token := check.Token()
+
+	err := conn.stor(name, []byte(token))
 	if err != nil {
-		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
-		return result
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not upload file: %w", err)
 	}
 
-	// Check if content matches regex
-	if !regex.Match(content) {
-		result.Message = "Matching content not found"
-		return result
+	content, err := conn.retr(name)
+	if err != nil {
+		conn.dele(name) //nolint:errcheck
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not download file: %w", err)
+	}
+	if string(content) != token {
+		conn.dele(name) //nolint:errcheck
+		return check.ContentMismatch, fmt.Errorf("contents downloaded did not match what was uploaded")
 	}
 
-	// If we reach here the check is successful
-	result.Passed = true
-	return result
+	err = conn.dele(name)
+	if err != nil {
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not delete file: %w", err)
+	}
+
+	return check.None, nil
 }
 
 // GetConfig returns the current CheckConfig struct this check has been
@@ -0,0 +1,73 @@
+package ftp
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestRun(t *testing.T) {
+	config := certificate(t)
+	files := func() map[string]string {
+		return map[string]string{"pub/motd.txt": "Welcome to Team 01\n", "pub/readme.txt": "Nothing to see here\n"}
+	}
+	plain := (&server{password: "changeme", files: files()}).serve(t)
+	explicit := (&server{password: "changeme", files: files(), tls: config}).serve(t)
+	implicit := (&server{password: "changeme", files: files(), tls: config, implicit: true}).serve(t)
+	digest := sha3.Sum256([]byte("Welcome to Team 01\n"))
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"File", Definition{Port: plain, File: "pub/motd.txt", ContentRegex: "^Welcome"}, true, check.None},
+		{"WrongContent", Definition{Port: plain, File: "pub/motd.txt", ContentRegex: "^Hacked"}, false, check.ContentMismatch},
+		{"MissingFile", Definition{Port: plain, File: "pub/news.txt"}, false, check.ContentMismatch},
+		{"Hash", Definition{Port: plain, File: "pub/motd.txt", HashContentMatch: "true", Hash: hex.EncodeToString(digest[:])}, true, check.None},
+		{"WrongHash", Definition{Port: plain, File: "pub/motd.txt", HashContentMatch: "true", Hash: "00"}, false, check.ContentMismatch},
+		{"Directory", Definition{Port: plain, Directory: "pub", Entries: []string{"motd.txt", "readme.txt"}}, true, check.None},
+		{"MissingEntry", Definition{Port: plain, Directory: "pub", Entries: []string{"flag.txt"}}, false, check.ContentMismatch},
+		{"WriteFile", Definition{Port: plain, WriteFile: "pub/token.txt"}, true, check.None},
+		{"Active", Definition{Port: plain, File: "pub/motd.txt", Mode: "active"}, true, check.None},
+		{"Simple", Definition{Port: plain, File: "pub", Simple: "true"}, true, check.None},
+		{"SimpleMissing", Definition{Port: plain, File: "home", Simple: "true"}, false, check.ContentMismatch},
+		{"WrongPassword", Definition{Port: plain, Password: "password"}, false, check.Auth},
+		{"Explicit", Definition{Port: explicit, TLS: "explicit", WriteFile: "pub/token.txt"}, true, check.None},
+		{"ExplicitUnsupported", Definition{Port: plain, TLS: "explicit"}, false, check.TLS},
+		{"Implicit", Definition{Port: implicit, TLS: "implicit", File: "pub/motd.txt"}, true, check.None},
+		{"Unverified", Definition{Port: explicit, TLS: "explicit", Verify: "true"}, false, check.TLS},
+		{"InvalidTLS", Definition{Port: plain, TLS: "always"}, false, check.DefinitionError},
+		{"InvalidMode", Definition{Port: plain, Mode: "extended"}, false, check.DefinitionError},
+		{"Regex", Definition{Port: plain, ContentRegex: "("}, false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "127.0.0.1"
+			d.Username = "admin"
+			if d.Password == "" {
+				d.Password = "changeme"
+			}
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Passed && r.Details["banner"] != "Stand-in FTP server ready" {
+				t.Errorf("banner = %q, want %q", r.Details["banner"], "Stand-in FTP server ready")
+			}
+		})
+	}
+}
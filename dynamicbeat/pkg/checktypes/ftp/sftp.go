@@ -0,0 +1,107 @@
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/sftp"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	sshcheck "github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// A session runs the file operations the check needs, over either FTP or
+// SFTP.
+type session interface {
+	retr(name string) ([]byte, error)
+	stor(name string, data []byte) error
+	dele(name string) error
+	nlst(dir string) ([]string, error)
+	quit() error
+}
+
+// An sftpSession runs the check's file operations over SFTP, using the SSH
+// check's client setup to log in.
+type sftpSession struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+}
+
+// dialSFTP logs in to an SSH server with a password and starts an SFTP
+// session. If it fails, the result's failure and message are set, and nil is
+// returned.
+func dialSFTP(ctx context.Context, addr string, username string, password string, hostKey string, result *check.Result) *sftpSession {
+	conn := sshcheck.Login(ctx, addr, username, sshcheck.PasswordAuth(password), hostKey, result)
+	if conn == nil {
+		return nil
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Error starting SFTP session : %s", err)
+		return nil
+	}
+
+	return &sftpSession{ssh: conn, client: client}
+}
+
+// retr downloads a file.
+func (s *sftpSession) retr(name string) ([]byte, error) {
+	f, err := s.client.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(io.LimitReader(f, maxFile))
+}
+
+// stor uploads a file.
+func (s *sftpSession) stor(name string, data []byte) error {
+	f, err := s.client.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// dele deletes a file.
+func (s *sftpSession) dele(name string) error {
+	return s.client.Remove(name)
+}
+
+// nlst returns the names of the entries in a directory.
+func (s *sftpSession) nlst(dir string) ([]string, error) {
+	entries, err := s.client.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
+
+// quit ends the SFTP session and closes the SSH connection.
+func (s *sftpSession) quit() error {
+	err := s.client.Close()
+	closeErr := s.ssh.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,176 @@
+package ftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"golang.org/x/crypto/sha3"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSFTP starts a stand-in SSH server that only runs the SFTP subsystem,
+// keeps files in memory, and accepts admin with the password changeme. The
+// files are created before the server's port and host key are returned.
+func serveSFTP(t *testing.T, files map[string]string) (string, ssh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(password) == "changeme" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		},
+	}
+	config.AddHostKey(hostSigner)
+	handlers := sftp.InMemHandler()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handleSFTP(conn, config, handlers)
+		}
+	}()
+
+	// Create the files over SFTP
+	conn, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.Password("changeme")},
+		HostKeyCallback: ssh.FixedHostKey(hostSigner.PublicKey()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for name, content := range files {
+		err = client.MkdirAll(sftp.Join("/", name, ".."))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := client.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write([]byte(content))
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), hostSigner.PublicKey()
+}
+
+func handleSFTP(conn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type == "subsystem" && string(req.Payload[4:]) == "sftp" {
+					_ = req.Reply(true, nil)
+					_ = sftp.NewRequestServer(channel, handlers).Serve()
+					return
+				}
+				_ = req.Reply(false, nil)
+			}
+		}()
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	port, hostKey := serveSFTP(t, map[string]string{"/pub/motd.txt": "Welcome to Team 01\n", "/pub/readme.txt": "Nothing to see here\n"})
+	digest := sha3.Sum256([]byte("Welcome to Team 01\n"))
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"Login", Definition{}, true, check.None},
+		{"File", Definition{File: "/pub/motd.txt", ContentRegex: "^Welcome"}, true, check.None},
+		{"WrongContent", Definition{File: "/pub/motd.txt", ContentRegex: "^Hacked"}, false, check.ContentMismatch},
+		{"MissingFile", Definition{File: "/pub/news.txt"}, false, check.ContentMismatch},
+		{"Hash", Definition{File: "/pub/motd.txt", HashContentMatch: "true", Hash: hex.EncodeToString(digest[:])}, true, check.None},
+		{"Directory", Definition{Directory: "/pub", Entries: []string{"motd.txt", "readme.txt"}}, true, check.None},
+		{"MissingEntry", Definition{Directory: "/pub", Entries: []string{"flag.txt"}}, false, check.ContentMismatch},
+		{"WriteFile", Definition{WriteFile: "/pub/token.txt"}, true, check.None},
+		{"HostKey", Definition{HostKey: ssh.FingerprintSHA256(hostKey)}, true, check.None},
+		{"WrongHostKey", Definition{HostKey: "SHA256:AAAA"}, false, check.ContentMismatch},
+		{"WrongPassword", Definition{Password: "password"}, false, check.Auth},
+		{"TLS", Definition{TLS: "explicit"}, false, check.DefinitionError},
+		{"Active", Definition{Mode: "active"}, false, check.DefinitionError},
+		{"Simple", Definition{File: "/pub", Simple: "true"}, false, check.DefinitionError},
+		{"InvalidProtocol", Definition{Protocol: "scp"}, false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host, d.Port = "127.0.0.1", port
+			d.Username = "admin"
+			if d.Protocol == "" {
+				d.Protocol = "sftp"
+			}
+			if d.Password == "" {
+				d.Password = "changeme"
+			}
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Passed && r.Details["host_key"] != ssh.FingerprintSHA256(hostKey) {
+				t.Errorf("host_key = %q, want %q", r.Details["host_key"], ssh.FingerprintSHA256(hostKey))
+			}
+		})
+	}
+}
@@ -15,6 +15,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "git",
+		Description: "Clone a Git repository",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the Git check and implements the "check" interface.
 type Definition struct {
 	Config          check.Config // Generic metadata about the check
@@ -64,7 +72,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 
 	// Clone the Git repository into memory
 	// We only clone the latest commit from a single branch to minimize memory usage
-	repo, err := git.Clone(store, tree, &git.CloneOptions{
+	repo, err := git.CloneContext(ctx, store, tree, &git.CloneOptions{
 		URL:             repoUrl.String(),
 		ReferenceName:   plumbing.NewBranchReferenceName(d.Branch),
 		SingleBranch:    true,
@@ -73,6 +81,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	})
 
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Failed to clone the repositoy: %s", err)
 		return result
 	}
@@ -83,6 +92,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		// Open the file for reading
 		file, err := tree.Open(d.ContentFile)
 		if err != nil {
+			result.Failure = check.ContentMismatch
 			result.Message = fmt.Sprintf("Unable to find %s in repository: %s", d.ContentFile, err)
 			return result
 		}
@@ -91,6 +101,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		// Read the contents of the file
 		content, err := io.ReadAll(file)
 		if err != nil {
+			result.Failure = check.Protocol
 			result.Message = fmt.Sprintf("Failed to read file %s contents: %s", d.ContentFile, err)
 			return result
 		}
@@ -98,12 +109,14 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		// Compile the regex statement
 		regex, err := regexp.Compile(d.ContentRegex)
 		if err != nil {
+			result.Failure = check.DefinitionError
 			result.Message = fmt.Sprintf("Failed to compile regex '%s': %s", d.ContentRegex, err)
 			return result
 		}
 
 		// Compare the regex with the checked file's contents
 		if !regex.Match(content) {
+			result.Failure = check.ContentMismatch
 			result.Message = "Matching content not found"
 			return result
 		}
@@ -117,12 +130,14 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		// Get the latest commit
 		head, err := repo.Head()
 		if err != nil {
+			result.Failure = check.Protocol
 			result.Message = fmt.Sprintf("Failed to get where HEAD is point to: %s", err)
 			return result
 		}
 
 		// Compare the hashes
 		if d.CommitHash != head.Hash().String() {
+			result.Failure = check.ContentMismatch
 			result.Message = "Commit hash does not match"
 			return result
 		}
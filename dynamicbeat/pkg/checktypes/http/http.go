@@ -3,7 +3,6 @@ package http
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,32 +14,65 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/jmespath/go-jmespath"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
-	"go.uber.org/zap"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "http",
+		Description: "Make a series of HTTP requests and check the responses",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of an HTTP check.
 type Definition struct {
 	Config               check.Config // generic metadata about the check
-	Verify               string       `optiontype:"optional"` // whether HTTPS certs should be validated
-	ReportMatchedContent string       `optiontype:"optional"` // whether the matched content should be returned in the CheckResult
-	Requests             []*Request   `optiontype:"list"`     // a list of requests to make
+	Verify               string       `optiontype:"optional"`                      // whether HTTPS certs should be validated
+	ReportMatchedContent string       `optiontype:"optional"`                      // whether the matched content should be returned in the CheckResult
+	Requests             []*Request   `optiontype:"list"`                          // a list of requests to make
+	ReportVariables      []string     `optiontype:"optional"`                      // names of variables to include in the CheckResult
+	CABundle             string       `optiontype:"optional"`                      // PEM-encoded CA certificates to validate HTTPS certs against, instead of the system roots
+	ClientCert           string       `optiontype:"optional"`                      // PEM-encoded client certificate to present to HTTPS servers
+	ClientKey            string       `optiontype:"optional"`                      // PEM-encoded private key for the client certificate
+	Proxy                string       `optiontype:"optional"`                      // URL of an http, https, or socks5 proxy to send requests through
+	Protocol             string       `optiontype:"optional" optiondefault:"auto"` // HTTP version to use: auto, http1, or http2
+	SourceIP             string       `optiontype:"optional"`                      // local IP to send requests from
+	IPVersion            string       `optiontype:"optional"`                      // IP version to connect with: 4 or 6
 }
 
 // A Request represents a single HTTP request to make.
 type Request struct {
-	Host         string            `optiontype:"required"`                     // IP or FQDN of the HTTP server
-	Path         string            `optiontype:"required"`                     // Path to request - see RFC3986, section 3.3
-	HTTPS        bool              `optiontype:"optional"`                     // if HTTPS is to be used
-	Port         uint16            `optiontype:"optional" optiondefault:"80"`  // TCP port number the HTTP server is listening on
-	Method       string            `optiontype:"optional" optiondefault:"GET"` // HTTP method to use
-	Headers      map[string]string `optiontype:"optional"`                     // name-value pairs of header fields to add/override
-	Body         string            `optiontype:"optional"`                     // the request body
-	MatchCode    bool              `optiontype:"optional"`                     // whether the response code must match a defined value for the check to pass
-	Code         int               `optiontype:"optional" optiondefault:"200"` // the response status code to match
-	MatchContent bool              `optiontype:"optional"`                     // whether the response body must match a defined regex for the check to pass
-	ContentRegex string            `optiontype:"optional" optiondefault:".*"`  // regex for the response body to match
-	StoreValue   bool              `optiontype:"optional"`                     // whether the matched content should be saved for use in a later request
+	Host            string            `optiontype:"required"`                          // IP or FQDN of the HTTP server
+	Path            string            `optiontype:"required"`                          // Path to request - see RFC3986, section 3.3
+	HTTPS           bool              `optiontype:"optional"`                          // if HTTPS is to be used
+	Port            uint16            `optiontype:"optional" optiondefault:"80"`       // TCP port number the HTTP server is listening on
+	Method          string            `optiontype:"optional" optiondefault:"GET"`      // HTTP method to use
+	Headers         map[string]string `optiontype:"optional"`                          // name-value pairs of header fields to add/override
+	Body            string            `optiontype:"optional"`                          // the request body
+	MatchCode       bool              `optiontype:"optional"`                          // whether the response code must match a defined value for the check to pass
+	Code            int               `optiontype:"optional" optiondefault:"200"`      // the response status code to match
+	MatchContent    bool              `optiontype:"optional"`                          // whether the response body must match a defined regex for the check to pass
+	ContentRegex    string            `optiontype:"optional" optiondefault:".*"`       // regex for the response body to match
+	StoreValue      bool              `optiontype:"optional"`                          // whether the matched content should be saved for use in a later request
+	BodyFile        string            `optiontype:"optional"`                          // file in the files_dir directory to template and use as the request body
+	MatchHeaders    map[string]string `optiontype:"optional"`                          // names of response headers and regexes their values must match
+	MatchJSON       map[string]string `optiontype:"optional"`                          // JMESPath expressions and regexes their values in the response body must match
+	FollowRedirects string            `optiontype:"optional" optiondefault:"true"`     // whether redirects should be followed
+	MaxRedirects    int               `optiontype:"optional" optiondefault:"10"`       // the most redirects to follow before failing
+	FinalURL        string            `optiontype:"optional"`                          // the URL the final response must come from after following redirects
+	MaxBodySize     int64             `optiontype:"optional" optiondefault:"10485760"` // the largest response body, in bytes, that will be read
+	ExtractHeaders  map[string]string `optiontype:"optional"`                          // names of variables and the response headers to save in them
+	ExtractCookies  map[string]string `optiontype:"optional"`                          // names of variables and the cookies to save in them
+	ExtractJSON     map[string]string `optiontype:"optional"`                          // names of variables and JMESPath expressions for the values in the response body to save in them
+	Normalize       string            `optiontype:"optional" optiondefault:"none"`     // how to normalize the response body before hashing or comparing it: none, whitespace, or text
+	MatchHash       bool              `optiontype:"optional"`                          // whether the hash of the response body must match a defined value for the check to pass
+	Hash            string            `optiontype:"optional"`                          // the sha256 hash to compare the normalized response body to
+	Baseline        string            `optiontype:"optional"`                          // the expected contents of the response body, to compare the normalized response body to
+	MinSimilarity   float64           `optiontype:"optional" optiondefault:"0.9"`      // how similar the response body must be to the baseline, from 0 to 1
+	MustNotContain  []string          `optiontype:"optional"`                          // regexes the response body must not match
 }
 
 // Run a single instance of the check.
@@ -49,79 +81,69 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
 	// Convert strings to booleans to allow templating
-	verify, _ := strconv.ParseBool(d.Verify)
 	reportMatchedContent, _ := strconv.ParseBool(d.ReportMatchedContent)
 
 	// Configure HTTP client
 	cookieJar, err := cookiejar.New(nil)
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = "Could not create CookieJar"
 		return result
 	}
-	// TODO: change http.Client.Timeout to be relative to the parent context's
-	// timeout
+	transport, err := d.transport()
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Could not configure HTTP client : %s", err)
+		return result
+	}
+	defer transport.CloseIdleConnections()
+	if d.Protocol == "http2" {
+		// HTTP/2 is only negotiated over TLS
+		for _, r := range d.Requests {
+			if !r.HTTPS {
+				result.Failure = check.DefinitionError
+				result.Message = fmt.Sprintf("Protocol http2 requires HTTPS, but the request to %s%s doesn't use it", r.Host, r.Path)
+				return result
+			}
+		}
+	}
+	// Each request is bound to the check's context, so the client doesn't
+	// need its own timeout
 	client := &http.Client{
-		Jar: cookieJar,
-		Transport: &http.Transport{
-			IdleConnTimeout: 10 * time.Second,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: !verify,
-			},
-		},
+		Jar:       cookieJar,
+		Transport: transport,
 	}
 
-	// Save match strings
+	// Variables start out as the check's attributes, and are added to by
+	// each request. Values saved from responses are also kept separately, so
+	// that only they can be reported, and attributes like passwords can't.
+	vars := d.Config.Attributes.Merged()
+	captured := make(map[string]string)
 	var lastMatch *string
-	var storedValue *string
-
-	type storedValTempl struct {
-		SavedValue string
-	}
 
 	// Make each request in the list
-	// TODO: use "happy line" structure instead of deeply-nested if statements
 	for _, r := range d.Requests {
-		// Check to see if the StoredValue needs to be templated in
-		if storedValue != nil {
-			// Re-encode definition to JSON string
-			def, err := json.Marshal(r)
-			if err != nil {
-				zap.S().Warnf("Error encoding HTTP definition as JSON for StoredValue templating: %s", err)
-			} else {
-				attrs := storedValTempl{
-					SavedValue: *storedValue,
-				}
-				templ := template.Must(template.New("http-storedvalue").Parse(string(def)))
-				var buf bytes.Buffer
-				err := templ.Execute(&buf, attrs)
-				if err != nil {
-					zap.S().Warnf("Error templating HTTP definition for StoredValue templating: %s", err)
-				} else {
-					newReq := &Request{}
-					err := json.Unmarshal(buf.Bytes(), &newReq)
-					if err != nil {
-						zap.S().Warnf("Error decoding StoredValue-templated HTTP definition: %s", err)
-					} else {
-						r = newReq
-					}
-				}
-			}
-		}
+		req := r.expand(vars)
 
-		// TODO: create child context with deadline less than the parent context
-		pass, match, err := request(ctx, client, *r)
+		tr := newTracer()
+		pass, match, failure, err := request(tr.context(ctx), client, req, vars, captured, d.Protocol == "http2")
+		tr.record(&result)
 
 		// Process request results
 		result.Passed = pass
 		if err != nil {
+			result.Failure = failure
 			result.Message = fmt.Sprintf("%s", err)
 		}
 		if match != nil {
 			lastMatch = match
 			if r.StoreValue {
-				storedValue = match
+				captured["SavedValue"] = *match
 			}
 		}
+		for name, value := range captured {
+			vars[name] = value
+		}
 
 		// If this request failed, don't continue on to the next request
 		if !pass {
@@ -133,12 +155,17 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	if reportMatchedContent && lastMatch != nil {
 		details["matched_content"] = *lastMatch
 	}
+	for _, name := range d.ReportVariables {
+		if v, ok := captured[name]; ok {
+			details[name] = v
+		}
+	}
 	result.Details = details
 
 	return result
 }
 
-func request(ctx context.Context, client *http.Client, r Request) (bool, *string, error) {
+func request(ctx context.Context, client *http.Client, r Request, vars map[string]string, captured map[string]string, http2 bool) (bool, *string, check.Failure, error) {
 	// Construct URL
 	var schema string
 	if r.HTTPS {
@@ -148,10 +175,20 @@ func request(ctx context.Context, client *http.Client, r Request) (bool, *string
 	}
 	url := fmt.Sprintf("%s://%s:%d%s", schema, r.Host, r.Port, r.Path)
 
+	// Load the request body from a file, if necessary
+	body := r.Body
+	if r.BodyFile != "" {
+		var err error
+		body, err = bodyFile(r.BodyFile, vars)
+		if err != nil {
+			return false, nil, check.DefinitionError, fmt.Errorf("Error loading request body from %s: %s", r.BodyFile, err)
+		}
+	}
+
 	// Construct request
-	req, err := http.NewRequestWithContext(ctx, r.Method, url, strings.NewReader(r.Body))
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, strings.NewReader(body))
 	if err != nil {
-		return false, nil, fmt.Errorf("Error constructing request: %s", err)
+		return false, nil, check.DefinitionError, fmt.Errorf("Error constructing request: %s", err)
 	}
 
 	// Handle Host header specially if present
@@ -165,41 +202,204 @@ func request(ctx context.Context, client *http.Client, r Request) (bool, *string
 		req.Header[k] = []string{v}
 	}
 
+	// Apply this request's redirect policy
+	follow, _ := strconv.ParseBool(r.FollowRedirects)
+	c := *client
+	c.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > r.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", r.MaxRedirects)
+		}
+		return nil
+	}
+
 	// Send request
-	resp, err := client.Do(req)
+	resp, err := c.Do(req)
 	if err != nil {
-		return false, nil, fmt.Errorf("Error making request: %s", err)
+		return false, nil, check.Classify(err, check.Protocol), fmt.Errorf("Error making request: %s", err)
 	}
 	defer resp.Body.Close()
 
+	// Make sure HTTP/2 was used, if it was required
+	if http2 && resp.ProtoMajor != 2 {
+		return false, nil, check.Protocol, fmt.Errorf("Server responded with %s instead of HTTP/2", resp.Proto)
+	}
+
 	// Check status code
 	if r.MatchCode && resp.StatusCode != r.Code {
-		return false, nil, fmt.Errorf("Recieved bad status code: %d", resp.StatusCode)
+		return false, nil, check.ContentMismatch, fmt.Errorf("Recieved bad status code: %d", resp.StatusCode)
 	}
 
-	// Check body content
-	var matchStr string
-	if r.MatchContent {
-		// Read response body
-		body, err := io.ReadAll(resp.Body)
+	// Check where the redirects ended up
+	if r.FinalURL != "" && resp.Request.URL.String() != r.FinalURL {
+		return false, nil, check.ContentMismatch, fmt.Errorf("Redirected to %s instead of %s", resp.Request.URL, r.FinalURL)
+	}
+
+	// Check response headers
+	for name, pattern := range r.MatchHeaders {
+		regex, err := regexp.Compile(pattern)
 		if err != nil {
-			return false, nil, fmt.Errorf("Recieved error when reading response body: %s", err)
+			return false, nil, check.DefinitionError, fmt.Errorf("Error compiling regex string %s : %s", pattern, err)
+		}
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			return false, nil, check.ContentMismatch, fmt.Errorf("Response did not include the %s header", name)
+		}
+		if !regex.MatchString(strings.Join(values, ", ")) {
+			return false, nil, check.ContentMismatch, fmt.Errorf("Recieved bad value for the %s header", name)
 		}
+	}
 
+	// Save values from the response's headers and cookies
+	err = extractHeaders(resp, client.Jar, r, captured)
+	if err != nil {
+		return false, nil, check.ContentMismatch, err
+	}
+
+	var matchStr string
+	if !r.MatchContent && len(r.MatchJSON) == 0 && len(r.ExtractJSON) == 0 && !r.MatchHash && r.Baseline == "" && len(r.MustNotContain) == 0 {
+		// If we've reached this point, then the check succeeded
+		return true, &matchStr, check.None, nil
+	}
+
+	// Read response body, but not more than the limit
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, r.MaxBodySize+1))
+	if err != nil {
+		return false, nil, check.Classify(err, check.Protocol), fmt.Errorf("Recieved error when reading response body: %s", err)
+	}
+	if int64(len(respBody)) > r.MaxBodySize {
+		return false, nil, check.ContentMismatch, fmt.Errorf("Response body is larger than %d bytes", r.MaxBodySize)
+	}
+
+	// Check body content
+	if r.MatchContent {
 		// Check if body matches regex
 		regex, err := regexp.Compile(r.ContentRegex)
 		if err != nil {
-			return false, nil, fmt.Errorf("Error compiling regex string %s : %s", r.ContentRegex, err)
+			return false, nil, check.DefinitionError, fmt.Errorf("Error compiling regex string %s : %s", r.ContentRegex, err)
 		}
-		if !regex.Match(body) {
-			return false, nil, fmt.Errorf("recieved bad response body")
+		if !regex.Match(respBody) {
+			return false, nil, check.ContentMismatch, fmt.Errorf("recieved bad response body")
 		}
-		matches := regex.FindSubmatch(body)
+		matches := regex.FindSubmatch(respBody)
 		matchStr = string(matches[len(matches)-1])
+
+		// Save any named groups
+		for i, name := range regex.SubexpNames() {
+			if name != "" && matches[i] != nil {
+				captured[name] = string(matches[i])
+			}
+		}
+	}
+
+	// Check for content that indicates the page was defaced
+	for _, pattern := range r.MustNotContain {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, nil, check.DefinitionError, fmt.Errorf("Error compiling regex string %s : %s", pattern, err)
+		}
+		if found := regex.Find(respBody); found != nil {
+			return false, nil, check.ContentMismatch, fmt.Errorf("Response body contains forbidden content: %s", found)
+		}
+	}
+
+	// Check the integrity of the body
+	if r.MatchHash || r.Baseline != "" {
+		normalized, err := normalize(respBody, r.Normalize)
+		if err != nil {
+			return false, nil, check.DefinitionError, err
+		}
+
+		if digest := sha256Hex(normalized); r.MatchHash && digest != strings.ToLower(r.Hash) {
+			return false, nil, check.ContentMismatch, fmt.Errorf("Incorrect hash: got %s", digest)
+		}
+
+		if r.Baseline != "" {
+			baseline, _ := normalize([]byte(r.Baseline), r.Normalize)
+			if score := similarity(baseline, normalized); score < r.MinSimilarity {
+				return false, nil, check.ContentMismatch, fmt.Errorf("Response body is %.0f%% similar to the baseline, less than the required %.0f%%", score*100, r.MinSimilarity*100)
+			}
+		}
+	}
+
+	// Check and save values in a JSON body
+	if len(r.MatchJSON) > 0 || len(r.ExtractJSON) > 0 {
+		var doc interface{}
+		err = json.Unmarshal(respBody, &doc)
+		if err != nil {
+			return false, nil, check.ContentMismatch, fmt.Errorf("Response body is not valid JSON: %s", err)
+		}
+		err = extractJSON(doc, r, captured)
+		if err != nil {
+			return false, nil, check.ContentMismatch, err
+		}
+		for expr, pattern := range r.MatchJSON {
+			value, err := jsonValue(doc, expr)
+			if err != nil {
+				return false, nil, check.DefinitionError, fmt.Errorf("Error evaluating JMESPath expression %s : %s", expr, err)
+			}
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, nil, check.DefinitionError, fmt.Errorf("Error compiling regex string %s : %s", pattern, err)
+			}
+			if value == nil || !regex.MatchString(*value) {
+				return false, nil, check.ContentMismatch, fmt.Errorf("Recieved bad value for %s in response body", expr)
+			}
+		}
 	}
 
 	// If we've reached this point, then the check succeeded
-	return true, &matchStr, nil
+	return true, &matchStr, check.None, nil
+}
+
+// bodyFile loads a request body from a file in the files_dir directory, and
+// templates it with the check's variables.
+func bodyFile(name string, vars map[string]string) (string, error) {
+	contents, err := files.Read(name)
+	if err != nil {
+		return "", err
+	}
+
+	templ, err := template.New(name).Parse(string(contents))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = templ.Execute(&buf, vars)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// jsonValue evaluates a JMESPath expression against a decoded JSON document.
+// Strings are returned as-is, and other values are encoded as JSON. If the
+// expression doesn't match anything, nil is returned.
+func jsonValue(doc interface{}, expr string) (*string, error) {
+	result, err := jmespath.Search(expr, doc)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	if s, ok := result.(string); ok {
+		return &s, nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	s := string(encoded)
+	return &s, nil
+}
+
+// RuntimeVariables returns the variables that older check definitions refer to
+// with templates. {{.SavedValue}} is the value saved by an earlier request
+// with StoreValue, which isn't known until the check runs.
+func (d *Definition) RuntimeVariables() []string {
+	return []string{"SavedValue"}
 }
 
 // GetConfig returns the current CheckConfig struct this check has been
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
+)
+
+// serve starts a stand-in web application for the checks to test.
+func serve(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Version", "2.4.1")
+		fmt.Fprint(w, `{"status": "ok", "db": {"connected": true}, "users": [{"name": "admin"}]}`)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		if form.Get("user") != "admin" || form.Get("password") != "changeme" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		w.Header().Set("X-Token", "abc123")
+		fmt.Fprint(w, `<p>Welcome, admin! Your account is <b id="acct">4021</b></p>`)
+	})
+	mux.HandleFunc("/account/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, "Account %s", filepath.Base(r.URL.Path))
+	})
+	mux.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-CSRF-Token", "q+Z/9.x(1)?=")
+		fmt.Fprint(w, `<form><input name="csrf" value="q+Z/9.x(1)?="></form>`)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "moved")
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><h1>Team 01 Store</h1><p>Welcome to our store!</p></body></html>")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newRequest creates a request to the server with the same defaults that
+// check definitions get.
+func newRequest(server *httptest.Server, path string) *Request {
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	return &Request{
+		Host:            u.Hostname(),
+		Path:            path,
+		Port:            uint16(port),
+		Method:          "GET",
+		Code:            200,
+		ContentRegex:    ".*",
+		FollowRedirects: "true",
+		MaxRedirects:    10,
+		MaxBodySize:     10485760,
+		Normalize:       "none",
+		MinSimilarity:   0.9,
+	}
+}
+
+// with modifies a request and returns it.
+func with(r *Request, modify func(r *Request)) *Request {
+	modify(r)
+	return r
+}
+
+// run runs a check against the server and makes sure it has the expected
+// outcome.
+func run(t *testing.T, d Definition, passed bool, failure check.Failure) check.Result {
+	t.Helper()
+	if d.Protocol == "" {
+		d.Protocol = "auto"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := d.Run(ctx)
+	if r.Passed != passed || r.Failure != failure {
+		t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, passed, failure, r.Message)
+	}
+
+	return r
+}
+
+func TestAssertions(t *testing.T) {
+	server := serve(t)
+	status := func(modify func(r *Request)) *Request {
+		return with(newRequest(server, "/api/status"), modify)
+	}
+
+	cases := []struct {
+		name    string
+		request *Request
+		passed  bool
+		failure check.Failure
+	}{
+		{"Header", status(func(r *Request) { r.MatchHeaders = map[string]string{"x-version": `^2\.`} }), true, check.None},
+		{"WrongHeader", status(func(r *Request) { r.MatchHeaders = map[string]string{"X-Version": `^3\.`} }), false, check.ContentMismatch},
+		{"MissingHeader", status(func(r *Request) { r.MatchHeaders = map[string]string{"X-Powered-By": ".*"} }), false, check.ContentMismatch},
+		{"HeaderRegex", status(func(r *Request) { r.MatchHeaders = map[string]string{"X-Version": "("} }), false, check.DefinitionError},
+		{"JSON", status(func(r *Request) {
+			r.MatchJSON = map[string]string{"status": "^ok$", "db.connected": "^true$", "users[0].name": "admin"}
+		}), true, check.None},
+		{"WrongJSON", status(func(r *Request) { r.MatchJSON = map[string]string{"status": "^down$"} }), false, check.ContentMismatch},
+		{"MissingJSON", status(func(r *Request) { r.MatchJSON = map[string]string{"cache.connected": ".*"} }), false, check.ContentMismatch},
+		{"JMESPath", status(func(r *Request) { r.MatchJSON = map[string]string{"users[": ".*"} }), false, check.DefinitionError},
+		{"NotJSON", with(newRequest(server, "/"), func(r *Request) { r.MatchJSON = map[string]string{"status": ".*"} }), false, check.ContentMismatch},
+		{"Redirect", with(newRequest(server, "/old"), func(r *Request) {
+			r.FinalURL = server.URL + "/new"
+			r.MatchContent, r.ContentRegex = true, "^moved$"
+		}), true, check.None},
+		{"WrongRedirect", with(newRequest(server, "/old"), func(r *Request) { r.FinalURL = server.URL + "/old" }), false, check.ContentMismatch},
+		{"NoFollow", with(newRequest(server, "/old"), func(r *Request) {
+			r.FollowRedirects = "false"
+			r.MatchCode, r.Code = true, http.StatusFound
+			r.MatchHeaders = map[string]string{"Location": "^/new$"}
+		}), true, check.None},
+		{"TooManyRedirects", with(newRequest(server, "/loop"), func(r *Request) { r.MaxRedirects = 3 }), false, check.Protocol},
+		{"BodyTooLarge", with(newRequest(server, "/"), func(r *Request) {
+			r.MatchContent, r.MaxBodySize = true, 16
+		}), false, check.ContentMismatch},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run(t, Definition{Requests: []*Request{c.request}}, c.passed, c.failure)
+		})
+	}
+}
+
+func TestBodyFile(t *testing.T) {
+	server := serve(t)
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "login.txt"), []byte("user={{.user}}&password={{.password}}"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Configure(dir)
+	defer files.Configure("")
+
+	d := Definition{Requests: []*Request{with(newRequest(server, "/login"), func(r *Request) {
+		r.Method, r.BodyFile = "POST", "login.txt"
+		r.MatchCode = true
+	})}}
+	d.Config.Attributes.Admin = map[string]string{"user": "admin"}
+	d.Config.Attributes.User = map[string]string{"password": "changeme"}
+	run(t, d, true, check.None)
+
+	d.Requests[0].BodyFile = "missing.txt"
+	run(t, d, false, check.DefinitionError)
+}
+
+func TestVariables(t *testing.T) {
+	server := serve(t)
+	login := with(newRequest(server, "/login"), func(r *Request) {
+		r.Method, r.Body = "POST", "user=${user}&password=${password}"
+		r.MatchContent, r.ContentRegex = true, `id="acct">(?P<account>\d+)<`
+		r.ExtractHeaders = map[string]string{"token": "X-Token"}
+		r.ExtractCookies = map[string]string{"session": "session"}
+	})
+	account := with(newRequest(server, "/account/${account}"), func(r *Request) {
+		r.Headers = map[string]string{"Authorization": "Bearer ${token}"}
+		r.MatchCode = true
+		r.MatchContent, r.ContentRegex = true, "^Account 4021$"
+	})
+
+	d := Definition{
+		Requests:        []*Request{login, account},
+		ReportVariables: []string{"token", "session", "account", "password", "missing"},
+	}
+	d.Config.Attributes.User = map[string]string{"user": "admin", "password": "changeme"}
+	r := run(t, d, true, check.None)
+
+	want := map[string]string{"token": "abc123", "session": "s3cr3t", "account": "4021"}
+	if !reflect.DeepEqual(r.Details, want) {
+		t.Errorf("Details = %v, want %v", r.Details, want)
+	}
+}
+
+func TestRegexVariable(t *testing.T) {
+	server := serve(t)
+	d := Definition{Requests: []*Request{
+		with(newRequest(server, "/form"), func(r *Request) {
+			r.ExtractHeaders = map[string]string{"csrf": "X-CSRF-Token"}
+		}),
+		with(newRequest(server, "/form"), func(r *Request) {
+			r.MatchContent, r.ContentRegex = true, `value="${csrf}"`
+			r.MatchHeaders = map[string]string{"X-CSRF-Token": "^${csrf}$"}
+		}),
+	}}
+	run(t, d, true, check.None)
+}
+
+func TestSavedValue(t *testing.T) {
+	server := serve(t)
+	d := Definition{Requests: []*Request{
+		with(newRequest(server, "/login"), func(r *Request) {
+			r.Method, r.Body = "POST", "user=admin&password=changeme"
+			r.MatchContent, r.ContentRegex, r.StoreValue = true, `id="acct">(\d+)<`, true
+		}),
+		with(newRequest(server, "/account/${SavedValue}"), func(r *Request) {
+			r.Headers = map[string]string{"Authorization": "Bearer abc123"}
+			r.MatchContent, r.ContentRegex = true, "^Account 4021$"
+		}),
+	}}
+	run(t, d, true, check.None)
+}
+
+func TestMissingVariable(t *testing.T) {
+	server := serve(t)
+	d := Definition{Requests: []*Request{with(newRequest(server, "/api/status"), func(r *Request) {
+		r.ExtractHeaders = map[string]string{"token": "X-Token"}
+	})}}
+	run(t, d, false, check.ContentMismatch)
+
+	d.Requests[0].ExtractHeaders = nil
+	d.Requests[0].ExtractJSON = map[string]string{"token": "auth.token"}
+	run(t, d, false, check.ContentMismatch)
+}
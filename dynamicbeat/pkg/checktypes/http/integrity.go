@@ -0,0 +1,70 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// hidden matches parts of an HTML document that aren't displayed
+	hidden = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<!--.*?-->`)
+	// tag matches HTML tags
+	tag = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// normalize prepares a response body to be hashed or compared, so that
+// insignificant differences don't cause the check to fail.
+//
+//   - none: the body is used as-is
+//   - whitespace: runs of whitespace are collapsed into single spaces
+//   - text: scripts, styles, comments, and tags are removed, entities are
+//     decoded, and whitespace is collapsed, leaving only the visible text
+func normalize(body []byte, mode string) (string, error) {
+	switch mode {
+	case "none":
+		return string(body), nil
+	case "whitespace":
+		return strings.Join(strings.Fields(string(body)), " "), nil
+	case "text":
+		text := hidden.ReplaceAllString(string(body), " ")
+		text = tag.ReplaceAllString(text, " ")
+		text = html.UnescapeString(text)
+		return strings.Join(strings.Fields(text), " "), nil
+	default:
+		return "", fmt.Errorf("invalid Normalize mode '%s' - must be one of none, whitespace, or text", mode)
+	}
+}
+
+// sha256Hex returns the hex-encoded SHA-256 digest of a string.
+func sha256Hex(s string) string {
+	digest := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(digest[:])
+}
+
+// similarity compares the words in two strings, and returns a score between 0
+// and 1 for how many words they share, regardless of order. Identical strings
+// have a score of 1, and strings with no words in common have a score of 0.
+func similarity(a string, b string) float64 {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA)+len(wordsB) == 0 {
+		return 1
+	}
+
+	counts := make(map[string]int, len(wordsA))
+	for _, w := range wordsA {
+		counts[w]++
+	}
+	shared := 0
+	for _, w := range wordsB {
+		if counts[w] > 0 {
+			counts[w]--
+			shared++
+		}
+	}
+
+	return 2 * float64(shared) / float64(len(wordsA)+len(wordsB))
+}
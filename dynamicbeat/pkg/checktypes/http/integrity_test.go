@@ -0,0 +1,90 @@
+package http
+
+import (
+	"math"
+	"testing"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestNormalize(t *testing.T) {
+	page := "<html>\n<head><style>h1 { color: red; }</style><script>track();</script></head>\n" +
+		"<body><!-- build 42 -->\n  <h1>Team&nbsp;01 &amp; Friends</h1>\n\t<p>Welcome!</p></body></html>"
+
+	cases := []struct {
+		mode string
+		body string
+		want string
+		err  bool
+	}{
+		{"none", " a  b ", " a  b ", false},
+		{"whitespace", " a \n\t b ", "a b", false},
+		{"text", page, "Team 01 & Friends Welcome!", false},
+		{"markdown", "a", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.mode, func(t *testing.T) {
+			got, err := normalize([]byte(c.body), c.mode)
+			if (err != nil) != c.err {
+				t.Fatalf("normalize() error = %v, want error: %t", err, c.err)
+			}
+			if got != c.want {
+				t.Errorf("normalize() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"welcome to the store", "welcome to the store", 1},
+		{"welcome to the store", "store the to welcome", 1},
+		{"welcome to the store", "hacked by team02", 0},
+		{"welcome to the store", "welcome to the shop", 0.75},
+		{"a a b", "a b b", 2.0 * 2 / 6},
+		{"a", "", 0},
+	}
+
+	for _, c := range cases {
+		if got := similarity(c.a, c.b); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("similarity(%q, %q) = %f, want %f", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	server := serve(t)
+	store := func(modify func(r *Request)) *Request {
+		return with(newRequest(server, "/"), modify)
+	}
+	text := "Team 01 Store Welcome to our store!"
+
+	cases := []struct {
+		name    string
+		request *Request
+		passed  bool
+		failure check.Failure
+	}{
+		{"Hash", store(func(r *Request) { r.MatchHash, r.Normalize, r.Hash = true, "text", sha256Hex(text) }), true, check.None},
+		{"WrongHash", store(func(r *Request) { r.MatchHash, r.Hash = true, sha256Hex(text) }), false, check.ContentMismatch},
+		{"Baseline", store(func(r *Request) {
+			r.Normalize, r.Baseline, r.MinSimilarity = "text", "<h1>Team 01 Store</h1> Welcome to our shop!", 0.8
+		}), true, check.None},
+		{"Defaced", store(func(r *Request) { r.Normalize, r.Baseline = "text", "Hacked by Team 02" }), false, check.ContentMismatch},
+		{"MustNotContain", store(func(r *Request) { r.MustNotContain = []string{"(?i)hacked", "(?i)pwned"} }), true, check.None},
+		{"Forbidden", store(func(r *Request) { r.MustNotContain = []string{"(?i)welcome"} }), false, check.ContentMismatch},
+		{"ForbiddenRegex", store(func(r *Request) { r.MustNotContain = []string{"("} }), false, check.DefinitionError},
+		{"Normalize", store(func(r *Request) { r.MatchHash, r.Normalize = true, "markdown" }), false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run(t, Definition{Requests: []*Request{c.request}}, c.passed, c.failure)
+		})
+	}
+}
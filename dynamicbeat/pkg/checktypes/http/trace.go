@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// A tracer measures the phases of a single HTTP request. The connection
+// phases are only measured if a new connection is opened for the request.
+type tracer struct {
+	mu      sync.Mutex
+	start   time.Time
+	dns     time.Time
+	connect time.Time
+	tls     time.Time
+	timings check.Timings
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now(), timings: make(check.Timings)}
+}
+
+// context adds the tracer's hooks to a request's context.
+func (t *tracer) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dns = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.done("dns", &t.dns)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connect = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			// Only the connection that was used should be measured
+			if err == nil {
+				t.done("connect", &t.connect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tls = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.done("tls", &t.tls)
+		},
+		GotFirstResponseByte: func() {
+			t.done("ttfb", &t.start)
+		},
+	})
+}
+
+func (t *tracer) done(phase string, start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timings[phase] = time.Since(*start)
+}
+
+// record adds the tracer's measurements to the check result.
+func (t *tracer) record(result *check.Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for phase, d := range t.timings {
+		result.Record(phase, d)
+	}
+}
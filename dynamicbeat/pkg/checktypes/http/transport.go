@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// transport creates the HTTP transport for a check, configured with the
+// check's TLS, proxy, protocol, and addressing options.
+func (d *Definition) transport() (*http.Transport, error) {
+	// Convert strings to booleans to allow templating
+	verify, _ := strconv.ParseBool(d.Verify)
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: !verify, //nolint:gosec
+	}
+	if d.CABundle != "" {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(d.CABundle)) {
+			return nil, fmt.Errorf("CABundle does not contain any PEM-encoded certificates")
+		}
+	}
+	if d.ClientCert != "" || d.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(d.ClientCert), []byte(d.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Choose the address family and source address for connections
+	network := "tcp"
+	switch d.IPVersion {
+	case "":
+	case "4", "6":
+		network += d.IPVersion
+	default:
+		return nil, fmt.Errorf("invalid IPVersion '%s' - must be either 4 or 6", d.IPVersion)
+	}
+	dialer := &net.Dialer{}
+	if d.SourceIP != "" {
+		ip := net.ParseIP(d.SourceIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid SourceIP '%s'", d.SourceIP)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	t := &http.Transport{
+		IdleConnTimeout: 10 * time.Second,
+		TLSClientConfig: tlsConfig,
+		DialContext: func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+
+	if d.Proxy != "" {
+		proxy, err := url.Parse(d.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Proxy: %s", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid Proxy scheme '%s' - must be one of http, https, or socks5", proxy.Scheme)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	// Custom dialers and TLS configs disable HTTP/2 unless it's requested, so
+	// auto keeps using HTTP/1.1 like checks always have
+	switch d.Protocol {
+	case "auto":
+	case "http1":
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case "http2":
+		t.ForceAttemptHTTP2 = true
+	default:
+		return nil, fmt.Errorf("invalid Protocol '%s' - must be one of auto, http1, or http2", d.Protocol)
+	}
+
+	return t, nil
+}
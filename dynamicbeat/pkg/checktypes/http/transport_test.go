@@ -0,0 +1,142 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestTransport(t *testing.T) {
+	cert, key := clientCertificate(t)
+
+	cases := []struct {
+		name string
+		def  Definition
+		err  bool
+	}{
+		{"Defaults", Definition{Protocol: "auto"}, false},
+		{"HTTP1", Definition{Protocol: "http1"}, false},
+		{"HTTP2", Definition{Protocol: "http2"}, false},
+		{"Protocol", Definition{Protocol: "spdy"}, true},
+		{"CABundle", Definition{Protocol: "auto", CABundle: "not a certificate"}, true},
+		{"ClientCert", Definition{Protocol: "auto", ClientCert: cert, ClientKey: key}, false},
+		{"ClientKey", Definition{Protocol: "auto", ClientCert: cert}, true},
+		{"IPVersion", Definition{Protocol: "auto", IPVersion: "6"}, false},
+		{"BadIPVersion", Definition{Protocol: "auto", IPVersion: "5"}, true},
+		{"SourceIP", Definition{Protocol: "auto", SourceIP: "127.0.0.1"}, false},
+		{"BadSourceIP", Definition{Protocol: "auto", SourceIP: "localhost"}, true},
+		{"Proxy", Definition{Protocol: "auto", Proxy: "socks5://127.0.0.1:1080"}, false},
+		{"ProxyScheme", Definition{Protocol: "auto", Proxy: "ftp://127.0.0.1:21"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := c.def.transport()
+			if (err != nil) != c.err {
+				t.Errorf("transport() error = %v, want error: %t", err, c.err)
+			}
+		})
+	}
+}
+
+// clientCertificate creates a self-signed client certificate, and returns it
+// and its private key in PEM format.
+func clientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scorestack"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// serveTLS starts an HTTPS server that reports the protocol version and
+// client certificate of each request in response headers.
+func serveTLS(t *testing.T, http2 bool, clientAuth tls.ClientAuthType) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	server.EnableHTTP2 = http2
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{ClientAuth: clientAuth}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestProtocols(t *testing.T) {
+	h2, h1 := serveTLS(t, true, tls.NoClientCert), serveTLS(t, false, tls.NoClientCert)
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: h2.Certificate().Raw}))
+	proto := func(server *httptest.Server, version string) *Request {
+		return with(newRequest(server, "/"), func(r *Request) {
+			r.HTTPS = true
+			r.MatchHeaders = map[string]string{"X-Proto": "^" + version + "$"}
+		})
+	}
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"Auto", Definition{Protocol: "auto", Requests: []*Request{proto(h2, "HTTP/1.1")}}, true, check.None},
+		{"HTTP1", Definition{Protocol: "http1", Requests: []*Request{proto(h2, "HTTP/1.1")}}, true, check.None},
+		{"HTTP2", Definition{Protocol: "http2", Requests: []*Request{proto(h2, "HTTP/2.0")}}, true, check.None},
+		{"NoHTTP2", Definition{Protocol: "http2", Requests: []*Request{proto(h1, ".*")}}, false, check.Protocol},
+		{"HTTP2WithoutHTTPS", Definition{Protocol: "http2", Requests: []*Request{newRequest(h2, "/")}}, false, check.DefinitionError},
+		{"Verify", Definition{Protocol: "auto", Verify: "true", Requests: []*Request{proto(h2, ".*")}}, false, check.TLS},
+		{"CABundle", Definition{Protocol: "auto", Verify: "true", CABundle: bundle, Requests: []*Request{proto(h2, ".*")}}, true, check.None},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			run(t, c.def, c.passed, c.failure)
+		})
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	server := serveTLS(t, false, tls.RequireAnyClientCert)
+	cert, key := clientCertificate(t)
+	r := with(newRequest(server, "/"), func(r *Request) {
+		r.HTTPS = true
+		r.MatchHeaders = map[string]string{"X-Client": "^scorestack$"}
+	})
+
+	run(t, Definition{Requests: []*Request{r}, ClientCert: cert, ClientKey: key}, true, check.None)
+	run(t, Definition{Requests: []*Request{r}}, false, check.TLS)
+}
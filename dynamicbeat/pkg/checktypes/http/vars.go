@@ -0,0 +1,111 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// variable matches references to variables, like ${token}.
+var variable = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expand replaces references to variables in a string with their values.
+// References to variables that haven't been set are left as-is.
+func expand(s string, vars map[string]string) string {
+	return variable.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, ok := vars[ref[2:len(ref)-1]]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// expandRegex replaces references to variables in a regex with their values,
+// escaped so that they only match themselves. Tokens often contain characters
+// like + and . that would otherwise change the meaning of the regex.
+func expandRegex(s string, vars map[string]string) string {
+	return variable.ReplaceAllStringFunc(s, func(ref string) string {
+		if v, ok := vars[ref[2:len(ref)-1]]; ok {
+			return regexp.QuoteMeta(v)
+		}
+		return ref
+	})
+}
+
+// expandMap replaces references to variables in each value of a map, using
+// the given expansion function.
+func expandMap(m map[string]string, vars map[string]string, expand func(string, map[string]string) string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	expanded := make(map[string]string, len(m))
+	for k, v := range m {
+		expanded[k] = expand(v, vars)
+	}
+
+	return expanded
+}
+
+// expand returns a copy of the request with the variables filled in to every
+// field that is sent to the server or compared against the response. Values
+// filled in to regexes are escaped.
+func (r *Request) expand(vars map[string]string) Request {
+	req := *r
+	req.Host = expand(r.Host, vars)
+	req.Path = expand(r.Path, vars)
+	req.Headers = expandMap(r.Headers, vars, expand)
+	req.Body = expand(r.Body, vars)
+	req.ContentRegex = expandRegex(r.ContentRegex, vars)
+	req.MatchHeaders = expandMap(r.MatchHeaders, vars, expandRegex)
+	req.MatchJSON = expandMap(r.MatchJSON, vars, expandRegex)
+	req.FinalURL = expand(r.FinalURL, vars)
+
+	return req
+}
+
+// extractHeaders saves the values of the response's headers and cookies into
+// variables. Cookies are looked up in the response first, and then in the
+// cookie jar, in case they were set earlier or during a redirect.
+func extractHeaders(resp *http.Response, jar http.CookieJar, r Request, vars map[string]string) error {
+	for name, header := range r.ExtractHeaders {
+		value := resp.Header.Get(header)
+		if value == "" {
+			return fmt.Errorf("Could not save variable %s : response did not include the %s header", name, header)
+		}
+		vars[name] = value
+	}
+
+	for name, cookie := range r.ExtractCookies {
+		found := false
+		cookies := append(resp.Cookies(), jar.Cookies(resp.Request.URL)...)
+		for _, c := range cookies {
+			if c.Name == cookie {
+				vars[name] = c.Value
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Could not save variable %s : no %s cookie was set", name, cookie)
+		}
+	}
+
+	return nil
+}
+
+// extractJSON saves values from a decoded JSON response body into variables.
+func extractJSON(doc interface{}, r Request, vars map[string]string) error {
+	for name, expr := range r.ExtractJSON {
+		value, err := jsonValue(doc, expr)
+		if err != nil {
+			return fmt.Errorf("Error evaluating JMESPath expression %s : %s", expr, err)
+		}
+		if value == nil {
+			return fmt.Errorf("Could not save variable %s : %s did not match anything in the response body", name, expr)
+		}
+		vars[name] = *value
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"token": "abc123", "id": "42", "empty": ""}
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"/users/${id}", "/users/42"},
+		{"Bearer ${token}", "Bearer abc123"},
+		{"${id}-${id}", "42-42"},
+		{"${missing}", "${missing}"},
+		{"x${empty}y", "xy"},
+		{"$id {id} ${ id }", "$id {id} ${ id }"},
+		{"no variables", "no variables"},
+	}
+
+	for _, c := range cases {
+		if got := expand(c.in, vars); got != c.want {
+			t.Errorf("expand(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestExpandRequest(t *testing.T) {
+	vars := map[string]string{"host": "web.team01.local", "token": "abc123", "csrf": "a+b.c(d)?"}
+	r := &Request{
+		Host:         "${host}",
+		Path:         "/api?token=${token}&csrf=${csrf}",
+		Headers:      map[string]string{"Authorization": "Bearer ${token}"},
+		ContentRegex: "${token} ${csrf}",
+		MatchHeaders: map[string]string{"X-CSRF-Token": "^${csrf}$"},
+		MatchJSON:    map[string]string{"token": "^${token}$"},
+	}
+
+	// Values are escaped in regexes, but not in anything sent to the server
+	got := r.expand(vars)
+	want := Request{
+		Host:         "web.team01.local",
+		Path:         "/api?token=abc123&csrf=a+b.c(d)?",
+		Headers:      map[string]string{"Authorization": "Bearer abc123"},
+		ContentRegex: `abc123 a\+b\.c\(d\)\?`,
+		MatchHeaders: map[string]string{"X-CSRF-Token": `^a\+b\.c\(d\)\?$`},
+		MatchJSON:    map[string]string{"token": "^abc123$"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expand() = %+v, want %+v", got, want)
+	}
+	if r.Headers["Authorization"] != "Bearer ${token}" {
+		t.Error("expand() modified the original request")
+	}
+}
@@ -11,6 +11,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "icmp",
+		Description: "Ping a host",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the ICMP check
 // it implements the "Check" interface
 type Definition struct {
@@ -29,19 +37,20 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Create pinger
 	pinger, err := ping.NewPinger(d.Host)
 	if err != nil {
+		result.Failure = check.Classify(err, check.DNSResolution)
 		result.Message = fmt.Sprintf("Error creating pinger: %s", err)
 		return result
 	}
 
 	// Send ping
 	pinger.Count = d.Count
-	// TODO: change this to be relative to the parent context's timeout
-	pinger.Timeout = 25 * time.Second
+	pinger.Timeout = check.Remaining(ctx)
 	_ = pinger.Run()
 
 	// Convert PassCount to bool
 	passCount, err := strconv.ParseBool(d.AllowPacketLoss)
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = fmt.Sprintf("Failed to parse PassCount boolean from struct def : %s", err)
 		return result
 	}
@@ -52,6 +61,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Check packet loss instead of count
 	if !passCount {
 		if stats.PacketLoss >= float64(d.Percent) {
+			result.Failure = check.Timeout
 			result.Message = "Not all pings made it back!"
 			details["packetloss_percent"] = strconv.FormatFloat(stats.PacketLoss, 'f', -1, 64)
 			result.Details = details
@@ -65,6 +75,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 
 	// Check for failure of ICMP
 	if stats.PacketsRecv != d.Count {
+		result.Failure = check.Timeout
 		result.Message = "Not all pings made it back!"
 		details["packets_received"] = fmt.Sprintf("%d", stats.PacketsRecv)
 		details["packets_expected"] = fmt.Sprintf("%d", d.Count)
@@ -14,6 +14,14 @@ import (
 	"go.uber.org/zap"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "imap",
+		Description: "Log in to an IMAP server and list the mailboxes",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the imap check
 // it implements the "check" interface
 type Definition struct {
@@ -32,9 +40,8 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
 	// Create a dialer so we can set timeouts
-	// TODO: change this to be relative to the parent context's timeout
 	dialer := net.Dialer{
-		Timeout: 20 * time.Second,
+		Deadline: check.Deadline(ctx),
 	}
 
 	// Defining these allow the if/else block below
@@ -43,11 +50,12 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 
 	// Connect to server with TLS or not
 	if encrypted, _ := strconv.ParseBool(d.Encrypted); encrypted {
-		c, err = client.DialWithDialerTLS(&dialer, fmt.Sprintf("%s:%s", d.Host, d.Port), &tls.Config{})
+		c, err = client.DialWithDialerTLS(&dialer, net.JoinHostPort(d.Host, d.Port), &tls.Config{})
 	} else {
-		c, err = client.DialWithDialer(&dialer, fmt.Sprintf("%s:%s", d.Host, d.Port))
+		c, err = client.DialWithDialer(&dialer, net.JoinHostPort(d.Host, d.Port))
 	}
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Connecting to server %s failed : %s", d.Host, err)
 		return result
 	}
@@ -59,11 +67,12 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	}()
 
 	// Set timeout for commands
-	c.Timeout = 5 * time.Second
+	c.Timeout = check.Remaining(ctx)
 
 	// Login
 	err = c.Login(d.Username, d.Password)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Login with user %s failed : %s", d.Username, err)
 		return result
 	}
@@ -72,6 +81,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	mailboxes := make(chan *imap.MailboxInfo, 10)
 	err = c.List("", "*", mailboxes)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Listing mailboxes failed : %s", err)
 		return result
 	}
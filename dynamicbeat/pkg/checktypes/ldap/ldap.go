@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"strconv"
 	"time"
 
@@ -11,6 +12,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "ldap",
+		Description: "Bind to an LDAP server",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the LDAP check
 // it implements the "check" interface
 type Definition struct {
@@ -27,25 +36,24 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Initialize empty result
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
-	// Set timeout
-	// TODO: change this to be relative to the parent context's timeout
-	ldap.DefaultTimeout = 20 * time.Second
-
 	// Normal, default ldap check
-	lconn, err := ldap.Dial("tcp", fmt.Sprintf("%s:%s", d.Fqdn, d.Port))
+	dialer := &net.Dialer{Deadline: check.Deadline(ctx)}
+	lconn, err := ldap.DialURL(fmt.Sprintf("ldap://%s", net.JoinHostPort(d.Fqdn, d.Port)), ldap.DialWithDialer(dialer))
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Could not dial server %s : %s", d.Fqdn, err)
 		return result
 	}
 	defer lconn.Close()
 
 	// Set message timeout
-	lconn.SetTimeout(5 * time.Second)
+	lconn.SetTimeout(check.Remaining(ctx))
 
 	// Add TLS if needed
 	if ldaps, _ := strconv.ParseBool(d.Ldaps); ldaps {
 		err = lconn.StartTLS(&tls.Config{InsecureSkipVerify: true})
 		if err != nil {
+			result.Failure = check.Classify(err, check.TLS)
 			result.Message = fmt.Sprintf("TLS session creation failed : %s", err)
 			return result
 		}
@@ -54,6 +62,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Attempt to login
 	err = lconn.Bind(d.User, d.Password)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Failed to login with user %s : %s", d.User, err)
 		return result
 	}
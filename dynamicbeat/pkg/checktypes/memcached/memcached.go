@@ -0,0 +1,160 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "memcached",
+		Description: "Write and read back a value in Memcached, or read an existing key",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// The Definition configures the behavior of the Memcached check
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Host         string       `optiontype:"required"`                       // IP or hostname of the Memcached server
+	Port         string       `optiontype:"optional" optiondefault:"11211"` // Port the server is listening on
+	Username     string       `optiontype:"optional"`                       // Username for ASCII authentication
+	Password     string       `optiontype:"optional"`                       // Password for the user
+	Key          string       `optiontype:"optional"`                       // Existing key to read, instead of writing a random value
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"`    // Regex the value of Key must match
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	if d.Key != "" && !validKey(d.Key) {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid key '%s' : keys can't contain whitespace and must be at most 250 bytes", d.Key)
+		return result
+	}
+
+	// Connect to the server
+	start := time.Now()
+	conn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Connection to %s failed : %s", d.Host, err)
+		return result
+	}
+	result.Time("connect", start)
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			zap.S().Warnf("Failed to close Memcached connection: %s", err)
+		}
+	}()
+
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+
+	// Authenticate, if the server requires it
+	if d.Username != "" {
+		start = time.Now()
+		err = c.auth(d.Username, d.Password)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Auth)
+			result.Message = fmt.Sprintf("Authentication failed : %s", err)
+			return result
+		}
+		result.Time("auth", start)
+	}
+
+	version, err := c.version()
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Failed to get server version : %s", err)
+		return result
+	}
+	result.Details = map[string]string{"version": version}
+
+	start = time.Now()
+	if d.Key != "" {
+		// Read the existing key
+		value, ok, err := c.get(d.Key)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to get key %s : %s", d.Key, err)
+			return result
+		}
+		if !ok {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Key %s does not exist", d.Key)
+			return result
+		}
+		if !regex.MatchString(value) {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Value of key %s did not match %s", d.Key, d.ContentRegex)
+			return result
+		}
+	} else {
+		// Write a random value, and make sure it can be read back. The key
+		// expires in case it can't be deleted.
+		key := check.Token()
+		value := check.Token()
+		err = c.set(key, value, 60)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to set key : %s", err)
+			return result
+		}
+		got, ok, err := c.get(key)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to get key : %s", err)
+			return result
+		}
+		if !ok || got != value {
+			result.Failure = check.ContentMismatch
+			result.Message = "Value read back from Memcached did not match the value written"
+			return result
+		}
+		err = c.delete(key)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to delete key : %s", err)
+			return result
+		}
+	}
+	result.Time("query", start)
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// validKey returns whether a key can be sent with the text protocol.
+func validKey(key string) bool {
+	return len(key) <= 250 && !strings.ContainsAny(key, " \t\r\n\x00")
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
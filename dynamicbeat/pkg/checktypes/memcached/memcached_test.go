@@ -0,0 +1,138 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// serve starts a stand-in Memcached server that keeps values in memory. If
+// password is set, clients must log in as admin before running any other
+// commands.
+func serve(t *testing.T, password string, values map[string]string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				authed := password == ""
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) == 0 {
+						continue
+					}
+
+					mu.Lock()
+					var reply string
+					switch {
+					case fields[0] == "set" && len(fields) == 5:
+						size, _ := strconv.Atoi(fields[4])
+						data := make([]byte, size+2)
+						_, _ = io.ReadFull(r, data)
+						value := string(data[:size])
+						switch {
+						case fields[1] == "auth":
+							authed = value == "admin "+password
+							reply = "STORED"
+							if !authed {
+								reply = "CLIENT_ERROR authentication failure"
+							}
+						case !authed:
+							reply = "CLIENT_ERROR unauthenticated"
+						default:
+							values[fields[1]] = value
+							reply = "STORED"
+						}
+					case !authed:
+						reply = "CLIENT_ERROR unauthenticated"
+					case fields[0] == "version":
+						reply = "VERSION 1.6.21"
+					case fields[0] == "get" && len(fields) == 2:
+						if value, ok := values[fields[1]]; ok {
+							reply = fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\nEND", fields[1], len(value), value)
+						} else {
+							reply = "END"
+						}
+					case fields[0] == "delete" && len(fields) == 2:
+						delete(values, fields[1])
+						reply = "DELETED"
+					default:
+						reply = "ERROR"
+					}
+					mu.Unlock()
+
+					_, _ = conn.Write([]byte(reply + "\r\n"))
+				}
+			}()
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRun(t *testing.T) {
+	values := map[string]string{"motd": "Welcome to Team 01"}
+	open, locked := serve(t, "", values), serve(t, "changeme", map[string]string{})
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"RoundTrip", Definition{Port: open}, true, check.None},
+		{"Key", Definition{Port: open, Key: "motd", ContentRegex: "^Welcome"}, true, check.None},
+		{"WrongValue", Definition{Port: open, Key: "motd", ContentRegex: "^Hacked"}, false, check.ContentMismatch},
+		{"MissingKey", Definition{Port: open, Key: "news"}, false, check.ContentMismatch},
+		{"InvalidKey", Definition{Port: open, Key: "two words"}, false, check.DefinitionError},
+		{"Regex", Definition{Port: open, ContentRegex: "("}, false, check.DefinitionError},
+		{"Auth", Definition{Port: locked, Username: "admin", Password: "changeme"}, true, check.None},
+		{"WrongPassword", Definition{Port: locked, Username: "admin", Password: "password"}, false, check.Auth},
+		{"NoAuth", Definition{Port: locked}, false, check.Protocol},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "127.0.0.1"
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+		})
+	}
+
+	if len(values) != 1 {
+		t.Errorf("the check left %d keys behind", len(values)-1)
+	}
+}
@@ -0,0 +1,136 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxValue is the largest value that will be read from the server.
+// Memcached limits values to 1 MiB by default, but the limit can be raised.
+const maxValue = 10 * 1024 * 1024
+
+// A client sends commands to a Memcached server using the text protocol.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// auth logs in with ASCII authentication, which is a set command whose value
+// is the username and password.
+func (c *client) auth(username string, password string) error {
+	err := c.set("auth", username+" "+password, 0)
+	if err != nil {
+		return fmt.Errorf("invalid credentials: %w", err)
+	}
+
+	return nil
+}
+
+// version returns the version of the server.
+func (c *client) version() (string, error) {
+	line, err := c.command("version\r\n")
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "VERSION ") {
+		return "", fmt.Errorf("unexpected reply: %s", line)
+	}
+
+	return strings.TrimPrefix(line, "VERSION "), nil
+}
+
+// set stores a value that expires after a number of seconds.
+func (c *client) set(key string, value string, expiry int) error {
+	line, err := c.command(fmt.Sprintf("set %s 0 %d %d\r\n%s\r\n", key, expiry, len(value), value))
+	if err != nil {
+		return err
+	}
+	if line != "STORED" {
+		return fmt.Errorf("unexpected reply: %s", line)
+	}
+
+	return nil
+}
+
+// get reads a value, and returns false if the key doesn't exist.
+func (c *client) get(key string) (string, bool, error) {
+	line, err := c.command(fmt.Sprintf("get %s\r\n", key))
+	if err != nil {
+		return "", false, err
+	}
+	if line == "END" {
+		return "", false, nil
+	}
+
+	// The value is sent after a line like VALUE <key> <flags> <bytes>
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "VALUE" {
+		return "", false, fmt.Errorf("unexpected reply: %s", line)
+	}
+	size, err := strconv.Atoi(fields[3])
+	if err != nil || size < 0 {
+		return "", false, fmt.Errorf("invalid value size: %s", fields[3])
+	}
+	if size > maxValue {
+		return "", false, fmt.Errorf("value of %d bytes is larger than the limit of %d bytes", size, maxValue)
+	}
+	value := make([]byte, size+2)
+	_, err = io.ReadFull(c.r, value)
+	if err != nil {
+		return "", false, err
+	}
+	line, err = c.readLine()
+	if err != nil {
+		return "", false, err
+	}
+	if line != "END" {
+		return "", false, fmt.Errorf("unexpected reply: %s", line)
+	}
+
+	return string(value[:size]), true, nil
+}
+
+// delete removes a key.
+func (c *client) delete(key string) error {
+	line, err := c.command(fmt.Sprintf("delete %s\r\n", key))
+	if err != nil {
+		return err
+	}
+	if line != "DELETED" {
+		return fmt.Errorf("unexpected reply: %s", line)
+	}
+
+	return nil
+}
+
+// command sends a command and returns the first line of the reply. Error
+// replies are returned as errors.
+func (c *client) command(command string) (string, error) {
+	_, err := c.conn.Write([]byte(command))
+	if err != nil {
+		return "", err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") || strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", fmt.Errorf("server returned %s", line)
+	}
+
+	return line, nil
+}
+
+func (c *client) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,109 @@
+package memcached
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// reply connects a client to a stand-in server that reads a single command,
+// including its data block if it has one, sends a canned reply, and hangs up.
+// The command the server received is sent on the returned channel.
+func reply(t *testing.T, canned string) (*client, <-chan string) {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+
+	received := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(serverConn)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 5 && fields[0] == "set" {
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			_, _ = io.ReadFull(r, data)
+			line += string(data)
+		}
+		received <- line
+		_, _ = serverConn.Write([]byte(canned))
+		serverConn.Close()
+	}()
+
+	return &client{conn: clientConn, r: bufio.NewReader(clientConn)}, received
+}
+
+func TestGet(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply string
+		value string
+		found bool
+		err   bool
+	}{
+		{"Value", "VALUE motd 0 5\r\nhello\r\nEND\r\n", "hello", true, false},
+		{"Empty", "VALUE motd 0 0\r\n\r\nEND\r\n", "", true, false},
+		{"CRLFInValue", "VALUE motd 0 4\r\na\r\nb\r\nEND\r\n", "a\r\nb", true, false},
+		{"Missing", "END\r\n", "", false, false},
+		{"NegativeSize", "VALUE motd 0 -1\r\nEND\r\n", "", false, true},
+		{"InvalidSize", "VALUE motd 0 lots\r\nEND\r\n", "", false, true},
+		{"TooLarge", "VALUE motd 0 " + strconv.Itoa(maxValue+1) + "\r\n", "", false, true},
+		{"Short", "VALUE motd 0 10\r\nhello\r\n", "", false, true},
+		{"NoEnd", "VALUE motd 0 5\r\nhello\r\nVALUE\r\n", "", false, true},
+		{"Header", "VALUE motd\r\n", "", false, true},
+		{"Error", "SERVER_ERROR out of memory\r\n", "", false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl, _ := reply(t, c.reply)
+			value, found, err := cl.get("motd")
+			if (err != nil) != c.err {
+				t.Fatalf("get() error = %v, want error: %t", err, c.err)
+			}
+			if value != c.value || found != c.found {
+				t.Errorf("get() = %q, %t, want %q, %t", value, found, c.value, c.found)
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	cases := []struct {
+		name    string
+		call    func(c *client) error
+		reply   string
+		command string
+		err     bool
+	}{
+		{"Set", func(c *client) error { return c.set("k", "v1", 60) }, "STORED\r\n", "set k 0 60 2\r\nv1\r\n", false},
+		{"NotStored", func(c *client) error { return c.set("k", "v1", 60) }, "NOT_STORED\r\n", "set k 0 60 2\r\nv1\r\n", true},
+		{"Auth", func(c *client) error { return c.auth("admin", "changeme") }, "STORED\r\n", "set auth 0 0 14\r\nadmin changeme\r\n", false},
+		{"BadAuth", func(c *client) error { return c.auth("admin", "wrong") }, "CLIENT_ERROR authentication failure\r\n", "set auth 0 0 11\r\nadmin wrong\r\n", true},
+		{"Delete", func(c *client) error { return c.delete("k") }, "DELETED\r\n", "delete k\r\n", false},
+		{"NotFound", func(c *client) error { return c.delete("k") }, "NOT_FOUND\r\n", "delete k\r\n", true},
+		{"Version", func(c *client) error { _, err := c.version(); return err }, "VERSION 1.6.21\r\n", "version\r\n", false},
+		{"BadVersion", func(c *client) error { _, err := c.version(); return err }, "ERROR\r\n", "version\r\n", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl, received := reply(t, c.reply)
+			err := c.call(cl)
+			if (err != nil) != c.err {
+				t.Fatalf("error = %v, want error: %t", err, c.err)
+			}
+			if command := <-received; command != c.command {
+				t.Errorf("server received %q, want %q", command, c.command)
+			}
+		})
+	}
+}
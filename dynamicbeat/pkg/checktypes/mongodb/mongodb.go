@@ -0,0 +1,218 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "mongodb",
+		Description: "Write and read back a document in MongoDB, or find existing documents",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// maxDocuments is the most documents that will be read from the results of a
+// find.
+const maxDocuments = 1000
+
+// The Definition configures the behavior of the MongoDB check
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Host         string       `optiontype:"required"`                       // IP or hostname of the MongoDB server
+	Port         string       `optiontype:"optional" optiondefault:"27017"` // Port the server is listening on
+	Username     string       `optiontype:"optional"`                       // Username to authenticate with
+	Password     string       `optiontype:"optional"`                       // Password for the user
+	AuthDatabase string       `optiontype:"optional" optiondefault:"admin"` // Database the user is defined in
+	Database     string       `optiontype:"required"`                       // Name of the database to use
+	Collection   string       `optiontype:"required"`                       // Name of the collection to use
+	TLS          string       `optiontype:"optional"`                       // Whether to use TLS
+	Verify       string       `optiontype:"optional"`                       // Whether TLS certificates should be validated
+	Filter       string       `optiontype:"optional"`                       // Extended JSON filter for finding existing documents, instead of writing a random document
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"`    // Regex that at least one found document must match, as extended JSON
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	var filter bson.D
+	if d.Filter != "" {
+		err = bson.UnmarshalExtJSON([]byte(d.Filter), false, &filter)
+		if err != nil {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Error parsing filter %s : %s", d.Filter, err)
+			return result
+		}
+	}
+
+	// Configure the client
+	timer := &check.DialTimer{}
+	opts := options.Client().
+		SetHosts([]string{net.JoinHostPort(d.Host, d.Port)}).
+		SetDirect(true).
+		SetDialer(timer).
+		SetRetryReads(false).
+		SetRetryWrites(false)
+	if d.Username != "" {
+		opts.SetAuth(options.Credential{Username: d.Username, Password: d.Password, AuthSource: d.AuthDatabase})
+	}
+	if useTLS, _ := strconv.ParseBool(d.TLS); useTLS {
+		verify, _ := strconv.ParseBool(d.Verify)
+		opts.SetTLSConfig(&tls.Config{ServerName: d.Host, InsecureSkipVerify: !verify}) //nolint:gosec
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Creating MongoDB client failed : %s", err)
+		return result
+	}
+	defer func() {
+		err = client.Disconnect(context.Background())
+		if err != nil {
+			zap.S().Warnf("Failed to close MongoDB connection: %s", err)
+		}
+	}()
+
+	// Connect and authenticate
+	start := time.Now()
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		result.Failure = check.Classify(cause(err), check.Auth)
+		result.Message = fmt.Sprintf("Failed to connect to MongoDB : %s", err)
+		return result
+	}
+	result.Record("connect", timer.Took())
+	result.Record("auth", time.Since(start)-timer.Took())
+
+	// Users can be allowed to read and write a collection without being
+	// allowed to run buildInfo, so the version is only reported if it's
+	// available
+	result.Details = make(map[string]string)
+	var info struct {
+		Version string `bson:"version"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info)
+	if err != nil {
+		zap.S().Debugf("Failed to get MongoDB server version: %s", err)
+	} else {
+		result.Details["version"] = info.Version
+	}
+
+	collection := client.Database(d.Database).Collection(d.Collection)
+	start = time.Now()
+	if d.Filter != "" {
+		// Find existing documents, and make sure one of them matches
+		cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(maxDocuments))
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to find documents : %s", err)
+			return result
+		}
+		var documents []bson.M
+		err = cursor.All(ctx, &documents)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to read documents : %s", err)
+			return result
+		}
+
+		matched := 0
+		for _, doc := range documents {
+			b, err := bson.MarshalExtJSON(doc, false, false)
+			if err == nil && regex.Match(b) {
+				matched++
+			}
+		}
+		result.Details["documents"] = strconv.Itoa(len(documents))
+		result.Details["matched_documents"] = strconv.Itoa(matched)
+		if matched == 0 {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("No documents matched %s", d.ContentRegex)
+			return result
+		}
+	} else {
+		// Write a random document, and make sure it can be read back
+		token := check.Token()
+		_, err = collection.InsertOne(ctx, bson.D{{Key: "_id", Value: token}, {Key: "value", Value: token}})
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to insert document : %s", err)
+			return result
+		}
+		var doc struct {
+			Value string `bson:"value"`
+		}
+		err = collection.FindOne(ctx, bson.D{{Key: "_id", Value: token}}).Decode(&doc)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to find document : %s", err)
+			return result
+		}
+		if doc.Value != token {
+			result.Failure = check.ContentMismatch
+			result.Message = "Document read back from MongoDB did not match the document written"
+			return result
+		}
+		_, err = collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: token}})
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to delete document : %s", err)
+			return result
+		}
+	}
+	result.Time("query", start)
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// cause finds the error that kept the client from connecting to the server.
+// The client keeps trying to connect until the check's deadline, so otherwise
+// every connection failure would look like a timeout.
+func cause(err error) error {
+	var selection topology.ServerSelectionError
+	if errors.As(err, &selection) {
+		for _, server := range selection.Desc.Servers {
+			if server.LastError != nil {
+				return server.LastError
+			}
+		}
+	}
+
+	return err
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
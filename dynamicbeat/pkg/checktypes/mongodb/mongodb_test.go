@@ -0,0 +1,365 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/xdg-go/scram"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// A server is a stand-in MongoDB server that keeps documents in memory. It
+// speaks just enough of the wire protocol for the check: the handshake,
+// SCRAM-SHA-256 authentication, and basic CRUD commands.
+type server struct {
+	password    string              // the password for the admin user, or empty if clients don't have to log in
+	collections map[string][]bson.D // the documents in each collection, by name
+	noBuildInfo bool                // whether buildInfo is refused, as it is for users without the clusterMonitor role
+	corrupt     bool                // whether find returns the wrong value for documents the check wrote
+
+	scram *scram.Server
+	mu    sync.Mutex
+}
+
+// serve starts the stand-in server and returns its port.
+func (s *server) serve(t *testing.T) string {
+	if s.password != "" {
+		client, err := scram.SHA256.NewClient("admin", s.password, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials := client.GetStoredCredentials(scram.KeyFactors{Salt: "scorestack", Iters: 4096})
+		s.scram, err = scram.SHA256.NewServer(func(user string) (scram.StoredCredentials, error) {
+			if user != "admin" {
+				return scram.StoredCredentials{}, errors.New("unknown user")
+			}
+			return credentials, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// handle answers the commands sent on a connection.
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	authed := s.password == ""
+	var conversation *scram.ServerConversation
+
+	for {
+		header := make([]byte, 16)
+		_, err := io.ReadFull(conn, header)
+		if err != nil {
+			return
+		}
+		length := binary.LittleEndian.Uint32(header[0:4])
+		requestID := binary.LittleEndian.Uint32(header[4:8])
+		opCode := binary.LittleEndian.Uint32(header[12:16])
+		if length < 16 {
+			return
+		}
+		body := make([]byte, length-16)
+		_, err = io.ReadFull(conn, body)
+		if err != nil {
+			return
+		}
+
+		var command bson.D
+		sequences := make(map[string][]bson.Raw)
+		switch opCode {
+		case opQuery:
+			// Skip the flags, the collection name, and the number of
+			// documents to skip and return
+			name := bytes.IndexByte(body[4:], 0)
+			if name < 0 {
+				return
+			}
+			err = bson.Unmarshal(body[4+name+1+8:], &command)
+		case opMsg:
+			command, err = readSections(body[4:], sequences)
+		default:
+			return
+		}
+		if err != nil || len(command) == 0 {
+			return
+		}
+
+		reply := s.run(command, sequences, &authed, &conversation)
+		doc, err := bson.Marshal(reply)
+		if err != nil {
+			return
+		}
+
+		var msg []byte
+		if opCode == opQuery {
+			msg = make([]byte, 36, 36+len(doc))
+			binary.LittleEndian.PutUint32(msg[12:16], opReply)
+			binary.LittleEndian.PutUint32(msg[32:36], 1) // number of documents returned
+		} else {
+			msg = make([]byte, 21, 21+len(doc))
+			binary.LittleEndian.PutUint32(msg[12:16], opMsg)
+		}
+		msg = append(msg, doc...)
+		binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+		binary.LittleEndian.PutUint32(msg[8:12], requestID)
+		_, err = conn.Write(msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readSections reads the sections of an OP_MSG. The command is in the body
+// section, and batches of documents can be sent in separate sequences.
+func readSections(b []byte, sequences map[string][]bson.Raw) (bson.D, error) {
+	var command bson.D
+	for len(b) > 0 {
+		kind := b[0]
+		b = b[1:]
+		if len(b) < 4 {
+			return nil, errors.New("truncated section")
+		}
+		size := int(binary.LittleEndian.Uint32(b))
+		if size > len(b) {
+			return nil, errors.New("truncated section")
+		}
+
+		switch kind {
+		case 0:
+			err := bson.Unmarshal(b[:size], &command)
+			if err != nil {
+				return nil, err
+			}
+		case 1:
+			section := b[4:size]
+			end := bytes.IndexByte(section, 0)
+			if end < 0 {
+				return nil, errors.New("invalid sequence")
+			}
+			name := string(section[:end])
+			for docs := section[end+1:]; len(docs) > 0; {
+				n := int(binary.LittleEndian.Uint32(docs))
+				sequences[name] = append(sequences[name], bson.Raw(docs[:n]))
+				docs = docs[n:]
+			}
+		default:
+			return nil, errors.New("unknown section kind")
+		}
+		b = b[size:]
+	}
+
+	return command, nil
+}
+
+// run runs a command and returns the reply.
+func (s *server) run(command bson.D, sequences map[string][]bson.Raw, authed *bool, conversation **scram.ServerConversation) bson.D {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := command[0].Key
+	args := make(map[string]interface{}, len(command))
+	for _, e := range command {
+		args[e.Key] = e.Value
+	}
+	collection, _ := command[0].Value.(string)
+	unauthorized := bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "command " + name + " requires authentication"}, {Key: "code", Value: 13}, {Key: "codeName", Value: "Unauthorized"}}
+
+	switch {
+	case name == "isMaster" || name == "ismaster" || name == "hello":
+		reply := bson.D{
+			{Key: "ismaster", Value: true},
+			{Key: "helloOk", Value: true},
+			{Key: "maxBsonObjectSize", Value: 16 * 1024 * 1024},
+			{Key: "maxMessageSizeBytes", Value: 48000000},
+			{Key: "maxWriteBatchSize", Value: 100000},
+			{Key: "localTime", Value: time.Now()},
+			{Key: "minWireVersion", Value: 0},
+			{Key: "maxWireVersion", Value: 17},
+		}
+		if _, ok := args["saslSupportedMechs"]; ok {
+			reply = append(reply, bson.E{Key: "saslSupportedMechs", Value: bson.A{"SCRAM-SHA-256"}})
+		}
+		return append(reply, bson.E{Key: "ok", Value: 1})
+	case name == "saslStart" || name == "saslContinue":
+		if s.scram == nil {
+			return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "Authentication failed."}, {Key: "code", Value: 18}, {Key: "codeName", Value: "AuthenticationFailed"}}
+		}
+		if name == "saslStart" {
+			*conversation = s.scram.NewConversation()
+		}
+		payload, _ := args["payload"].(primitive.Binary)
+		if *conversation == nil {
+			return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "No SASL session state found"}, {Key: "code", Value: 17}, {Key: "codeName", Value: "ProtocolError"}}
+		}
+		response, err := (*conversation).Step(string(payload.Data))
+		if err != nil {
+			return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "Authentication failed."}, {Key: "code", Value: 18}, {Key: "codeName", Value: "AuthenticationFailed"}}
+		}
+		*authed = (*conversation).Valid()
+		return bson.D{
+			{Key: "conversationId", Value: 1},
+			{Key: "done", Value: (*conversation).Done()},
+			{Key: "payload", Value: primitive.Binary{Data: []byte(response)}},
+			{Key: "ok", Value: 1},
+		}
+	case name == "ping":
+		return bson.D{{Key: "ok", Value: 1}}
+	case !*authed:
+		return unauthorized
+	case name == "buildInfo" || name == "buildinfo":
+		if s.noBuildInfo {
+			return unauthorized
+		}
+		return bson.D{{Key: "version", Value: "6.0.6"}, {Key: "ok", Value: 1}}
+	case name == "insert":
+		docs := sequences["documents"]
+		for _, doc := range docs {
+			var d bson.D
+			_ = bson.Unmarshal(doc, &d)
+			s.collections[collection] = append(s.collections[collection], d)
+		}
+		return bson.D{{Key: "n", Value: len(docs)}, {Key: "ok", Value: 1}}
+	case name == "find":
+		filter, _ := args["filter"].(bson.D)
+		batch := bson.A{}
+		for _, doc := range s.collections[collection] {
+			if matches(doc, filter) {
+				if s.corrupt {
+					doc = append(bson.D{}, doc...)
+					for i := range doc {
+						if doc[i].Key == "value" {
+							doc[i].Value = "hacked"
+						}
+					}
+				}
+				batch = append(batch, doc)
+			}
+		}
+		cursor := bson.D{{Key: "firstBatch", Value: batch}, {Key: "id", Value: int64(0)}, {Key: "ns", Value: "scorestack." + collection}}
+		return bson.D{{Key: "cursor", Value: cursor}, {Key: "ok", Value: 1}}
+	case name == "delete":
+		deleted := 0
+		for _, raw := range sequences["deletes"] {
+			var del struct {
+				Q bson.D `bson:"q"`
+			}
+			_ = bson.Unmarshal(raw, &del)
+			var kept []bson.D
+			for _, doc := range s.collections[collection] {
+				if matches(doc, del.Q) {
+					deleted++
+				} else {
+					kept = append(kept, doc)
+				}
+			}
+			s.collections[collection] = kept
+		}
+		return bson.D{{Key: "n", Value: deleted}, {Key: "ok", Value: 1}}
+	case name == "endSessions":
+		return bson.D{{Key: "ok", Value: 1}}
+	default:
+		return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "no such command: '" + name + "'"}, {Key: "code", Value: 59}, {Key: "codeName", Value: "CommandNotFound"}}
+	}
+}
+
+// matches reports whether every field in the filter has the same value in the
+// document.
+func matches(doc bson.D, filter bson.D) bool {
+	for _, f := range filter {
+		found := false
+		for _, e := range doc {
+			found = found || (e.Key == f.Key && e.Value == f.Value)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRun(t *testing.T) {
+	open := &server{collections: map[string][]bson.D{"flags": {{{Key: "_id", Value: "1"}, {Key: "team", Value: "01"}, {Key: "flag", Value: "FLAG{mongo}"}}}}}
+	locked := &server{password: "changeme", collections: map[string][]bson.D{}, noBuildInfo: true}
+	corrupt := &server{collections: map[string][]bson.D{}, corrupt: true}
+	openPort, lockedPort, corruptPort := open.serve(t), locked.serve(t), corrupt.serve(t)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		version string
+	}{
+		{"RoundTrip", Definition{Port: openPort, Collection: "scorestack"}, true, check.None, "6.0.6"},
+		{"Filter", Definition{Port: openPort, Collection: "flags", Filter: `{"team": "01"}`, ContentRegex: `FLAG\{mongo\}`}, true, check.None, "6.0.6"},
+		{"WrongContent", Definition{Port: openPort, Collection: "flags", Filter: `{"team": "01"}`, ContentRegex: `FLAG\{hacked\}`}, false, check.ContentMismatch, "6.0.6"},
+		{"NoDocuments", Definition{Port: openPort, Collection: "flags", Filter: `{"team": "02"}`}, false, check.ContentMismatch, "6.0.6"},
+		{"Corrupt", Definition{Port: corruptPort, Collection: "scorestack"}, false, check.ContentMismatch, "6.0.6"},
+		{"InvalidFilter", Definition{Port: openPort, Collection: "flags", Filter: `{"team": `}, false, check.DefinitionError, ""},
+		{"Regex", Definition{Port: openPort, Collection: "flags", ContentRegex: "("}, false, check.DefinitionError, ""},
+		{"Auth", Definition{Port: lockedPort, Collection: "scorestack", Username: "admin", Password: "changeme"}, true, check.None, ""},
+		{"WrongPassword", Definition{Port: lockedPort, Collection: "scorestack", Username: "admin", Password: "password"}, false, check.Auth, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "127.0.0.1"
+			d.Database = "scorestack"
+			d.AuthDatabase = "admin"
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Details["version"] != c.version {
+				t.Errorf("version = %q, want %q", r.Details["version"], c.version)
+			}
+		})
+	}
+
+	if len(open.collections["scorestack"]) != 0 || len(locked.collections["scorestack"]) != 0 {
+		t.Error("the check left documents behind")
+	}
+}
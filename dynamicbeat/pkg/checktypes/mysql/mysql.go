@@ -3,111 +3,70 @@ package mysql
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"regexp"
-	"strconv"
-	"time"
+	"net"
 
-	// MySQL driver
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/sqldb"
 )
 
-// The Definition configures the behavior of the MySQL check
-// it implements the "check" interface
-type Definition struct {
-	Config       check.Config // generic metadata about the check
-	Host         string       `optiontype:"required"`                      // IP of Hostname for the MySQL server
-	Username     string       `optiontype:"required"`                      // Username for the database
-	Password     string       `optiontype:"required"`                      // Password for the user
-	Database     string       `optiontype:"required"`                      // Name of the database to access
-	Table        string       `optiontype:"required"`                      // Name of the table to access
-	Column       string       `optiontype:"required"`                      // Name of the column to access
-	MatchContent string       `optiontype:"optional"`                      // Whether to perform a regex content match on the results of the query
-	ContentRegex string       `optiontype:"optional" optiondefault:".*"`   // Regex to match on
-	Port         string       `optiontype:"optional" optiondefault:"3306"` // Port for the server
+// The driver can only be given a custom dialer by registering it globally, so
+// each check passes its DialTimer to the dialer through the context
+const network = "timedtcp"
+
+func init() {
+	check.Register(check.Type{
+		Name:        "mysql",
+		Description: "Query a MySQL database",
+		New:         func() check.Check { return sqldb.New(engine) },
+	})
+	check.Register(check.Type{
+		Name:        "mariadb",
+		Description: "Query a MariaDB database",
+		New:         func() check.Check { return sqldb.New(engine) },
+	})
+
+	mysql.RegisterDialContext(network, func(ctx context.Context, addr string) (net.Conn, error) {
+		return sqldb.Timer(ctx).DialContext(ctx, "tcp", addr)
+	})
 }
 
-// Run a single instance of the check
-func (d *Definition) Run(ctx context.Context) check.Result {
-	// Initialize empty result
-	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
-
-	// Create DB handle
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", d.Username, d.Password, d.Host, d.Port, d.Database))
-	if err != nil {
-		result.Message = fmt.Sprintf("Creating database handle failed : %s", err)
-		return result
-	}
-	defer db.Close()
+// MariaDB speaks the same protocol as MySQL, so both use the same engine
+var engine = &sqldb.Engine{
+	Port:        "3306",
+	Open:        open,
+	Placeholder: func(int) string { return "?" },
+}
 
-	// Set connection parameters
-	db.SetMaxIdleConns(-1)
-	db.SetMaxOpenConns(1)
+func open(d *sqldb.Definition, _ *check.DialTimer) (*sql.DB, error) {
+	config := mysql.NewConfig()
+	config.User = d.Username
+	config.Passwd = d.Password
+	config.Net = network
+	config.Addr = net.JoinHostPort(d.Host, d.Port)
+	config.DBName = d.Database
 
-	// Check db connection
-	err = db.PingContext(ctx)
+	tlsConfig, err := d.TLSConfig()
 	if err != nil {
-		result.Message = fmt.Sprintf("Failed to ping database : %s", err)
+		return nil, err
 	}
-
-	// Query the DB
-	// TODO: This is SQL injectable. Figure out Paramerterized queries
-	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s;", d.Column, d.Table))
-	if err != nil {
-		result.Message = fmt.Sprintf("Could not query database : %s", err)
-		return result
-	}
-	defer rows.Close()
-
-	// Store the value from the column
-	var val string
-
-	// Perform regex matching, if necessary
-	if matchContent, _ := strconv.ParseBool(d.MatchContent); matchContent {
-		// Compile the regex
-		regex, err := regexp.Compile(d.ContentRegex)
+	if tlsConfig != nil {
+		// TLS configurations can only be given to the driver by registering
+		// them globally, but the connector keeps its own copy so the
+		// registration can be removed right away
+		name := check.Token()
+		err = mysql.RegisterTLSConfig(name, tlsConfig)
 		if err != nil {
-			result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
-			return result
-		}
-
-		// Check the rows
-		for rows.Next() {
-			// Grab a value
-			err := rows.Scan(&val)
-			if err != nil {
-				result.Message = fmt.Sprintf("Could not scan row values : %s", err)
-				return result
-			}
-			// Check value with regex
-			if regex.MatchString(val) {
-				// If we reach here the check passes
-				result.Passed = true
-				return result
-			}
-
+			return nil, err
 		}
+		defer mysql.DeregisterTLSConfig(name)
+		config.TLSConfig = name
 	}
 
-	// Check for error in the rows
-	if rows.Err() != nil {
-		result.Message = fmt.Sprintf("Something happened to the rows : %s", err)
-		return result
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// Check passes if we reach here
-	result.Passed = true
-	return result
-}
-
-// GetConfig returns the current CheckConfig struct this check has been
-// configured with
-func (d *Definition) GetConfig() check.Config {
-	return d.Config
-}
-
-// SetConfig reconfigures this check with a new CheckConfig struct
-func (d *Definition) SetConfig(c check.Config) {
-	d.Config = c
+	return sql.OpenDB(connector), nil
 }
@@ -8,6 +8,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "noop",
+		Description: "Always passes; used for testing attributes",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of a Noop check.
 type Definition struct {
 	Config  check.Config // generic metadata about the check
@@ -0,0 +1,51 @@
+package oracle
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	// Oracle driver
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/sqldb"
+	goora "github.com/sijms/go-ora/v2"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "oracle",
+		Description: "Query an Oracle database",
+		New:         func() check.Check { return sqldb.New(engine) },
+	})
+}
+
+var engine = &sqldb.Engine{
+	Port:        "1521",
+	Open:        open,
+	Placeholder: func(n int) string { return ":" + strconv.Itoa(n) },
+}
+
+func open(d *sqldb.Definition, timer *check.DialTimer) (*sql.DB, error) {
+	port, err := strconv.Atoi(d.Port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s: %w", d.Port, err)
+	}
+
+	tlsConfig, err := d.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	options := map[string]string{}
+	if tlsConfig != nil {
+		options["SSL"] = "true"
+	}
+
+	// The database is the name of the service to connect to
+	connector := goora.NewConnector(goora.BuildUrl(d.Host, port, d.Database, d.Username, d.Password, options)).(*goora.OracleConnector)
+	connector.Dialer(timer)
+	if tlsConfig != nil {
+		connector.WithTLSConfig(tlsConfig)
+	}
+
+	return sql.OpenDB(connector), nil
+}
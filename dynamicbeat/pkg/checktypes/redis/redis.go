@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "redis",
+		Description: "Write and read back a value in Redis, or read an existing key",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// The Definition configures the behavior of the Redis check
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Host         string       `optiontype:"required"`                      // IP or hostname of the Redis server
+	Port         string       `optiontype:"optional" optiondefault:"6379"` // Port the server is listening on
+	Username     string       `optiontype:"optional"`                      // Username for ACL authentication
+	Password     string       `optiontype:"optional"`                      // Password for the user, or the server's requirepass password
+	DB           int          `optiontype:"optional"`                      // Number of the database to select
+	TLS          string       `optiontype:"optional"`                      // Whether to use TLS
+	Verify       string       `optiontype:"optional"`                      // Whether TLS certificates should be validated
+	Key          string       `optiontype:"optional"`                      // Existing key to read, instead of writing a random value
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"`   // Regex the value of Key must match
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	useTLS, _ := strconv.ParseBool(d.TLS)
+	verify, _ := strconv.ParseBool(d.Verify)
+
+	// The client only applies its TLS settings with its own dialer, so TLS is
+	// set up here instead
+	timer := &check.DialTimer{}
+	client := redis.NewClient(&redis.Options{
+		Addr: net.JoinHostPort(d.Host, d.Port),
+		Dialer: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			conn, err := timer.DialContext(ctx, network, addr)
+			if err != nil || !useTLS {
+				return conn, err
+			}
+			return tls.Client(conn, &tls.Config{ServerName: d.Host, InsecureSkipVerify: !verify}), nil //nolint:gosec
+		},
+		Username:              d.Username,
+		Password:              d.Password,
+		DB:                    d.DB,
+		MaxRetries:            -1,
+		PoolSize:              1,
+		ContextTimeoutEnabled: true,
+	})
+	defer func() {
+		err = client.Close()
+		if err != nil {
+			zap.S().Warnf("Failed to close Redis connection: %s", err)
+		}
+	}()
+
+	// Connect and authenticate
+	start := time.Now()
+	err = client.Ping(ctx).Err()
+	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
+		result.Message = fmt.Sprintf("Failed to connect to Redis : %s", err)
+		return result
+	}
+	result.Record("connect", timer.Took())
+	result.Record("auth", time.Since(start)-timer.Took())
+
+	// INFO is often disabled with ACLs or rename-command, so the version is
+	// only reported if it's available
+	result.Details = make(map[string]string)
+	info, err := client.Info(ctx, "server").Result()
+	if err != nil {
+		zap.S().Debugf("Failed to get Redis server info: %s", err)
+	} else {
+		result.Details["version"] = version(info)
+	}
+
+	start = time.Now()
+	if d.Key != "" {
+		// Read the existing key
+		value, err := client.Get(ctx, d.Key).Result()
+		if errors.Is(err, redis.Nil) {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Key %s does not exist", d.Key)
+			return result
+		}
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to get key %s : %s", d.Key, err)
+			return result
+		}
+		if !regex.MatchString(value) {
+			result.Failure = check.ContentMismatch
+			result.Message = fmt.Sprintf("Value of key %s did not match %s", d.Key, d.ContentRegex)
+			return result
+		}
+	} else {
+		// Write a random value, and make sure it can be read back. The key
+		// expires in case it can't be deleted.
+		key := check.Token()
+		value := check.Token()
+		err = client.Set(ctx, key, value, time.Minute).Err()
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to set key : %s", err)
+			return result
+		}
+		got, err := client.Get(ctx, key).Result()
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to get key : %s", err)
+			return result
+		}
+		if got != value {
+			result.Failure = check.ContentMismatch
+			result.Message = "Value read back from Redis did not match the value written"
+			return result
+		}
+		err = client.Del(ctx, key).Err()
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Failed to delete key : %s", err)
+			return result
+		}
+	}
+	result.Time("query", start)
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// version finds the server's version in the server section of an INFO reply.
+func version(info string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if v := strings.TrimPrefix(line, "redis_version:"); v != line {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	return ""
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
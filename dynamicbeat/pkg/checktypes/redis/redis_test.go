@@ -0,0 +1,194 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// A server is a stand-in Redis server that keeps values in memory and only
+// speaks RESP2, like servers older than Redis 6.
+type server struct {
+	password string            // the requirepass password, or empty if clients don't have to log in
+	values   map[string]string // the keys in the database
+	noInfo   bool              // whether INFO is refused, as it often is with ACLs
+	corrupt  bool              // whether GET returns the wrong value for keys the check wrote
+
+	mu sync.Mutex
+}
+
+// serve starts the stand-in server and returns its port.
+func (s *server) serve(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// handle answers the commands sent on a connection.
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		var reply string
+		switch command := strings.ToUpper(args[0]); {
+		case command == "HELLO":
+			reply = "-ERR unknown command 'HELLO'"
+		case command == "AUTH":
+			authed = args[len(args)-1] == s.password
+			reply = "+OK"
+			if !authed {
+				reply = "-WRONGPASS invalid username-password pair or user is disabled."
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required."
+		case command == "PING":
+			reply = "+PONG"
+		case command == "SELECT":
+			reply = "+OK"
+		case command == "INFO" && s.noInfo:
+			reply = "-NOPERM this user has no permissions to run the 'info' command"
+		case command == "INFO":
+			info := "# Server\r\nredis_version:7.0.11\r\nredis_mode:standalone\r\n"
+			reply = fmt.Sprintf("$%d\r\n%s", len(info), info)
+		case command == "SET" && len(args) >= 3:
+			s.values[args[1]] = args[2]
+			reply = "+OK"
+		case command == "GET" && len(args) == 2:
+			value, ok := s.values[args[1]]
+			if s.corrupt && ok {
+				value = "hacked"
+			}
+			reply = fmt.Sprintf("$%d\r\n%s", len(value), value)
+			if !ok {
+				reply = "$-1"
+			}
+		case command == "DEL" && len(args) == 2:
+			_, ok := s.values[args[1]]
+			delete(s.values, args[1])
+			reply = ":0"
+			if ok {
+				reply = ":1"
+			}
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'", args[0])
+		}
+		s.mu.Unlock()
+
+		_, err = conn.Write([]byte(reply + "\r\n"))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid command: %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid argument: %q", line)
+		}
+		arg := make([]byte, size+2)
+		_, err = io.ReadFull(r, arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+
+	return args, nil
+}
+
+func TestRun(t *testing.T) {
+	open := &server{values: map[string]string{"motd": "Welcome to Team 01"}}
+	locked := &server{password: "changeme", values: map[string]string{}, noInfo: true}
+	corrupt := &server{values: map[string]string{}, corrupt: true}
+	openPort, lockedPort, corruptPort := open.serve(t), locked.serve(t), corrupt.serve(t)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		version string
+	}{
+		{"RoundTrip", Definition{Port: openPort}, true, check.None, "7.0.11"},
+		{"Key", Definition{Port: openPort, Key: "motd", ContentRegex: "^Welcome"}, true, check.None, "7.0.11"},
+		{"WrongValue", Definition{Port: openPort, Key: "motd", ContentRegex: "^Hacked"}, false, check.ContentMismatch, "7.0.11"},
+		{"MissingKey", Definition{Port: openPort, Key: "news"}, false, check.ContentMismatch, "7.0.11"},
+		{"Corrupt", Definition{Port: corruptPort}, false, check.ContentMismatch, "7.0.11"},
+		{"Regex", Definition{Port: openPort, ContentRegex: "("}, false, check.DefinitionError, ""},
+		{"Auth", Definition{Port: lockedPort, Password: "changeme"}, true, check.None, ""},
+		{"WrongPassword", Definition{Port: lockedPort, Password: "password"}, false, check.Auth, ""},
+		{"NoPassword", Definition{Port: lockedPort}, false, check.Auth, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "127.0.0.1"
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Details["version"] != c.version {
+				t.Errorf("version = %q, want %q", r.Details["version"], c.version)
+			}
+		})
+	}
+
+	if len(open.values) != 1 || len(locked.values) != 0 {
+		t.Errorf("the check left %d keys behind", len(open.values)-1+len(locked.values))
+	}
+}
@@ -0,0 +1,62 @@
+package script
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func (s *session) dnsModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "dns",
+		Members: starlark.StringDict{
+			"query": starlark.NewBuiltin("dns.query", s.query),
+		},
+	}
+}
+
+// query asks a DNS server for the records of a type for a name, and returns
+// the data from each record in the answer as a list of strings.
+func (s *session) query(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var server, name string
+	typ := "A"
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "server", &server, "name", &name, "type?", &typ)
+	if err != nil {
+		return nil, err
+	}
+
+	qtype, ok := dns.StringToType[strings.ToUpper(typ)]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown record type '%s'", b.Name(), typ)
+	}
+
+	// Use the default DNS port if the server doesn't include one
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	client := new(dns.Client)
+	in, _, err := client.ExchangeContext(s.ctx, msg, server)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	if in.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s: server responded with %s", b.Name(), dns.RcodeToString[in.Rcode])
+	}
+
+	answers := make([]starlark.Value, 0, len(in.Answer))
+	for _, rr := range in.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		answers = append(answers, starlark.String(strings.TrimPrefix(rr.String(), rr.Header().String())))
+	}
+
+	return starlark.NewList(answers), nil
+}
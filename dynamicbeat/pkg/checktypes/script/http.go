@@ -0,0 +1,79 @@
+package script
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// maxBody is the most of a response body that will be returned to a script.
+const maxBody = 1024 * 1024
+
+func (s *session) httpModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "http",
+		Members: starlark.StringDict{
+			"request": starlark.NewBuiltin("http.request", s.request),
+		},
+	}
+}
+
+// request makes an HTTP request and returns a struct with the status code,
+// headers, and body of the response. Redirects are followed.
+func (s *session) request(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url string
+	method := "GET"
+	var body string
+	headers := starlark.NewDict(0)
+	var verify bool
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "method?", &method, "body?", &body, "headers?", &headers, "verify?", &verify)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+	for _, item := range headers.Items() {
+		name, value := str(item[0]), str(item[1])
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: !verify},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	respHeaders := starlark.NewDict(len(resp.Header))
+	for name := range resp.Header {
+		_ = respHeaders.SetKey(starlark.String(name), starlark.String(resp.Header.Get(name)))
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("response"), starlark.StringDict{
+		"status":  starlark.MakeInt(resp.StatusCode),
+		"headers": respHeaders,
+		"body":    starlark.String(content),
+	}), nil
+}
@@ -0,0 +1,66 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func reModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "re",
+		Members: starlark.StringDict{
+			"search":  starlark.NewBuiltin("re.search", search),
+			"findall": starlark.NewBuiltin("re.findall", findall),
+		},
+	}
+}
+
+// search finds the first match of a regex in a string. It returns a list
+// containing the whole match followed by each capture group, or None if there
+// is no match.
+func search(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pattern", &pattern, "string", &s); err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+
+	matches := regex.FindStringSubmatch(s)
+	if matches == nil {
+		return starlark.None, nil
+	}
+
+	return stringList(matches), nil
+}
+
+// findall returns a list of every non-overlapping match of a regex in a
+// string.
+func findall(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pattern", &pattern, "string", &s); err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+
+	return stringList(regex.FindAllString(s, -1)), nil
+}
+
+func stringList(values []string) *starlark.List {
+	list := make([]starlark.Value, len(values))
+	for i, v := range values {
+		list[i] = starlark.String(v)
+	}
+
+	return starlark.NewList(list)
+}
@@ -0,0 +1,222 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "script",
+		Description: "Run a Starlark script that checks a service",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// maxSteps limits how much work a script can do, so that a script stuck in a
+// loop doesn't use a whole CPU until its deadline.
+const maxSteps = 10000000
+
+// The Definition configures the behavior of the script check
+// it implements the "check" interface
+type Definition struct {
+	Config check.Config // generic metadata about the check
+	Script string       `optiontype:"required"` // Starlark source code that defines a check(attrs) function
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	s := &session{ctx: ctx}
+	defer s.close()
+
+	// Stop the script when the check's deadline passes
+	thread := &starlark.Thread{
+		Name: d.Config.ID,
+		Print: func(_ *starlark.Thread, msg string) {
+			zap.S().Debugf("[%s] %s", d.Config.ID, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel("check deadline exceeded")
+		case <-done:
+		}
+	}()
+
+	// Load the script
+	globals, err := starlark.ExecFile(thread, d.Config.ID+".star", d.Script, s.modules())
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error loading script : %s", err)
+		return result
+	}
+	fn, ok := globals["check"].(starlark.Callable)
+	if !ok {
+		result.Failure = check.DefinitionError
+		result.Message = "Script does not define a check function"
+		return result
+	}
+
+	// Run the check function with the check's attributes
+	attrs := starlark.NewDict(0)
+	for k, v := range d.Config.Attributes.Merged() {
+		_ = attrs.SetKey(starlark.String(k), starlark.String(v))
+	}
+	value, err := starlark.Call(thread, fn, starlark.Tuple{attrs}, nil)
+	if err != nil {
+		result.Failure = classify(ctx, err)
+		result.Message = fmt.Sprintf("Script failed : %s", err)
+		return result
+	}
+
+	err = toResult(value, &result)
+	if err != nil {
+		result.Passed = false
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Script returned an invalid result : %s", err)
+		return result
+	}
+
+	return result
+}
+
+// classify determines the failure category for an error raised while the
+// script was running.
+func classify(ctx context.Context, err error) check.Failure {
+	if ctx.Err() != nil {
+		return check.Timeout
+	}
+
+	// Errors raised by the helpers are wrapped by the interpreter
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) && evalErr.Unwrap() == nil {
+		return check.Protocol
+	}
+
+	return check.Classify(err, check.Protocol)
+}
+
+// toResult copies the value returned by the check function into a check
+// result. The check function may return a bool, or a dict with the keys
+// passed, message, details, and failure.
+func toResult(value starlark.Value, result *check.Result) error {
+	switch v := value.(type) {
+	case starlark.Bool:
+		result.Passed = bool(v)
+		if !result.Passed {
+			result.Failure = check.ContentMismatch
+		}
+		return nil
+	case *starlark.Dict:
+		var passed starlark.Bool
+		var message, failure starlark.String
+		details := starlark.NewDict(0)
+		err := starlark.UnpackArgs("check", nil, v.Items(),
+			"passed", &passed,
+			"message?", &message,
+			"failure?", &failure,
+			"details?", &details,
+		)
+		if err != nil {
+			return err
+		}
+
+		result.Passed = bool(passed)
+		result.Message = string(message)
+		result.Details = make(map[string]string, details.Len())
+		for _, item := range details.Items() {
+			result.Details[str(item[0])] = str(item[1])
+		}
+		if !result.Passed {
+			result.Failure = check.Failure(failure)
+			switch {
+			case result.Failure == check.None:
+				result.Failure = check.ContentMismatch
+			case !result.Failure.Known():
+				return fmt.Errorf("unknown failure category '%s'", failure.GoString())
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("check function must return a bool or a dict, got %s", value.Type())
+	}
+}
+
+// str converts a Starlark value to a Go string without quoting strings.
+func str(v starlark.Value) string {
+	if s, ok := starlark.AsString(v); ok {
+		return s
+	}
+
+	return v.String()
+}
+
+// A session tracks the resources opened by a single run of a script, so they
+// can be cleaned up when the script finishes.
+type session struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	closers []io.Closer
+}
+
+func (s *session) track(c io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, c)
+}
+
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.closers {
+		_ = c.Close()
+	}
+}
+
+// modules returns the helper modules that scripts can use.
+func (s *session) modules() starlark.StringDict {
+	return starlark.StringDict{
+		"tcp":       s.tcpModule(),
+		"http":      s.httpModule(),
+		"dns":       s.dnsModule(),
+		"re":        reModule(),
+		"json":      json.Module,
+		"remaining": starlark.NewBuiltin("remaining", s.remaining),
+	}
+}
+
+// remaining returns the number of seconds left before the check's deadline.
+func (s *session) remaining(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+
+	return starlark.Float(check.Remaining(s.ctx).Seconds()), nil
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
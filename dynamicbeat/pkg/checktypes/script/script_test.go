@@ -0,0 +1,103 @@
+package script
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// listen starts a line-based echo server that greets each client, and returns
+// its port.
+func listen(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte("+OK ready\r\n"))
+				lines := bufio.NewScanner(conn)
+				for lines.Scan() {
+					_, _ = conn.Write([]byte("echo " + lines.Text() + "\r\n"))
+				}
+			}()
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRun(t *testing.T) {
+	port := listen(t)
+
+	cases := []struct {
+		name    string
+		script  string
+		passed  bool
+		failure check.Failure
+		message string
+		details map[string]string
+	}{
+		{"True", "def check(attrs):\n    return True\n", true, check.None, "", nil},
+		{"False", "def check(attrs):\n    return False\n", false, check.ContentMismatch, "", nil},
+		{"Dict", "def check(attrs):\n    return {'passed': False, 'failure': 'auth', 'message': 'denied', 'details': {'user': attrs['user'], 'tries': 2}}\n", false, check.Auth, "denied", map[string]string{"user": "admin", "tries": "2"}},
+		{"Attributes", "def check(attrs):\n    return attrs['user'] == 'admin'\n", true, check.None, "", nil},
+		{"Regex", "def check(attrs):\n    m = re.search(r'v(\\d+)\\.(\\d+)', 'version v2.14')\n    return {'passed': m[2] == '14' and len(re.findall('a', 'banana')) == 3}\n", true, check.None, "", nil},
+		{"JSON", "def check(attrs):\n    return json.decode('{\"ok\": true}')['ok']\n", true, check.None, "", nil},
+		{"TCP", "def check(attrs):\n    c = tcp.connect('127.0.0.1', attrs['port'])\n    c.expect('OK')\n    c.send('hello\\r\\n')\n    return {'passed': True, 'details': {'reply': c.expect('echo \\\\w+')}}\n", true, check.None, "", map[string]string{"reply": "echo hello"}},
+		{"Fail", "def check(attrs):\n    fail('service is down')\n", false, check.Protocol, "", nil},
+		{"Syntax", "def check(attrs)\n    return True\n", false, check.DefinitionError, "", nil},
+		{"NoFunction", "x = 1\n", false, check.DefinitionError, "Script does not define a check function", nil},
+		{"UnknownFailure", "def check(attrs):\n    return {'passed': False, 'failure': 'broken'}\n", false, check.DefinitionError, "Script returned an invalid result : unknown failure category 'broken'", nil},
+		{"BadResult", "def check(attrs):\n    return 'yes'\n", false, check.DefinitionError, "", nil},
+		{"Loop", "def check(attrs):\n    for i in range(100000000):\n        pass\n", false, check.Protocol, "", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Definition{Script: strings.Replace(c.script, "attrs['port']", port, 1)}
+			d.Config.Attributes.User = map[string]string{"user": "admin"}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if c.message != "" && r.Message != c.message {
+				t.Errorf("Message = %q, want %q", r.Message, c.message)
+			}
+			for k, v := range c.details {
+				if r.Details[k] != v {
+					t.Errorf("Details[%s] = %q, want %q", k, r.Details[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	// The server only greets the client, so the second expect waits forever
+	d := Definition{Script: "def check(attrs):\n    c = tcp.connect('127.0.0.1', " + listen(t) + ")\n    c.expect('OK')\n    c.expect('never')\n"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	r := d.Run(ctx)
+	if r.Failure != check.Timeout {
+		t.Errorf("Failure = %q, want %q: %s", r.Failure, check.Timeout, r.Message)
+	}
+}
@@ -0,0 +1,178 @@
+package script
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// maxRead is the most data that expect will buffer while waiting for a match.
+const maxRead = 1024 * 1024
+
+func (s *session) tcpModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "tcp",
+		Members: starlark.StringDict{
+			"connect": starlark.NewBuiltin("tcp.connect", s.connect),
+		},
+	}
+}
+
+// connect opens a TCP connection, optionally using TLS. All reads and writes
+// on the connection must finish before the check's deadline.
+func (s *session) connect(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var host string
+	var port int
+	var useTLS, verify bool
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "host", &host, "port", &port, "tls?", &useTLS, "verify?", &verify)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	nc, err := check.Dial(s.ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	s.track(nc)
+
+	if useTLS {
+		tc := tls.Client(nc, &tls.Config{ServerName: host, InsecureSkipVerify: !verify})
+		err = tc.HandshakeContext(s.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+		nc = tc
+	}
+
+	return &conn{addr: addr, conn: nc}, nil
+}
+
+// A conn is a TCP connection that can be used by a script.
+type conn struct {
+	addr string
+	conn net.Conn
+	buf  []byte // data that has been read but not yet returned to the script
+}
+
+var connMethods = map[string]*starlark.Builtin{
+	"send":   starlark.NewBuiltin("send", connSend),
+	"recv":   starlark.NewBuiltin("recv", connRecv),
+	"expect": starlark.NewBuiltin("expect", connExpect),
+	"close":  starlark.NewBuiltin("close", connClose),
+}
+
+func (c *conn) String() string        { return fmt.Sprintf("<tcp.conn %s>", c.addr) }
+func (c *conn) Type() string          { return "tcp.conn" }
+func (c *conn) Freeze()               {}
+func (c *conn) Truth() starlark.Bool  { return true }
+func (c *conn) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable: %s", c.Type()) }
+
+func (c *conn) Attr(name string) (starlark.Value, error) {
+	if m, ok := connMethods[name]; ok {
+		return m.BindReceiver(c), nil
+	}
+	return nil, nil
+}
+
+func (c *conn) AttrNames() []string {
+	return []string{"close", "expect", "recv", "send"}
+}
+
+// send writes data to the connection.
+func connSend(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c := b.Receiver().(*conn)
+	var data string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "data", &data); err != nil {
+		return nil, err
+	}
+
+	_, err := c.conn.Write([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+
+	return starlark.None, nil
+}
+
+// recv returns up to size bytes of data, waiting for at least one byte to
+// arrive if none have been buffered.
+func connRecv(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c := b.Receiver().(*conn)
+	size := 4096
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "size?", &size); err != nil {
+		return nil, err
+	}
+
+	if len(c.buf) == 0 {
+		if err := c.fill(); err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name(), err)
+		}
+	}
+
+	if size > len(c.buf) {
+		size = len(c.buf)
+	}
+	data := string(c.buf[:size])
+	c.buf = c.buf[size:]
+
+	return starlark.String(data), nil
+}
+
+// expect reads from the connection until the data matches a regex. It returns
+// the matched text, and discards everything up to the end of the match.
+func connExpect(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c := b.Receiver().(*conn)
+	var pattern string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "pattern", &pattern); err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", b.Name(), err)
+	}
+
+	for {
+		if loc := regex.FindIndex(c.buf); loc != nil {
+			match := string(c.buf[loc[0]:loc[1]])
+			c.buf = c.buf[loc[1]:]
+			return starlark.String(match), nil
+		}
+		if len(c.buf) >= maxRead {
+			return nil, fmt.Errorf("%s: no match for %q in the first %d bytes", b.Name(), pattern, maxRead)
+		}
+
+		if err := c.fill(); err != nil {
+			return nil, fmt.Errorf("%s: no match for %q before error: %w", b.Name(), pattern, err)
+		}
+	}
+}
+
+// close closes the connection.
+func connClose(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c := b.Receiver().(*conn)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+
+	_ = c.conn.Close()
+	return starlark.None, nil
+}
+
+// fill reads the next chunk of data from the connection into the buffer.
+func (c *conn) fill() error {
+	chunk := make([]byte, 4096)
+	n, err := c.conn.Read(chunk)
+	c.buf = append(c.buf, chunk[:n]...)
+	if n > 0 {
+		return nil
+	}
+
+	return err
+}
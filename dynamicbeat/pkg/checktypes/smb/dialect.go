@@ -0,0 +1,63 @@
+package smb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// dialects maps the names of SMB dialects to their revision numbers.
+var dialects = map[string]uint16{
+	"2.0.2": 0x0202,
+	"2.1":   0x0210,
+	"3.0":   0x0300,
+	"3.0.2": 0x0302,
+	"3.1.1": 0x0311,
+}
+
+// negotiateLength is how much of the start of the stream holds the dialect
+// chosen by the server: the 4 byte transport header, the 64 byte SMB2 header,
+// and the first 6 bytes of the negotiate response.
+const negotiateLength = 4 + 64 + 6
+
+// A dialectConn records the start of the data sent by the server, so the
+// dialect it chose can be found. The SMB client doesn't report the dialect.
+type dialectConn struct {
+	net.Conn
+	head []byte
+}
+
+func (c *dialectConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if need := negotiateLength - len(c.head); need > 0 {
+		if n < need {
+			need = n
+		}
+		c.head = append(c.head, b[:need]...)
+	}
+
+	return n, err
+}
+
+// dialect returns the name of the dialect chosen by the server, from its
+// response to the negotiate request. Nothing is returned if the response is
+// an error, since it doesn't contain a dialect.
+func (c *dialectConn) dialect() string {
+	if len(c.head) < negotiateLength || string(c.head[4:8]) != "\xfeSMB" {
+		return ""
+	}
+	status := binary.LittleEndian.Uint32(c.head[12:16])
+	command := binary.LittleEndian.Uint16(c.head[16:18])
+	if status != 0 || command != 0 {
+		return ""
+	}
+
+	revision := binary.LittleEndian.Uint16(c.head[72:74])
+	for name, r := range dialects {
+		if r == revision {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%#x", revision)
+}
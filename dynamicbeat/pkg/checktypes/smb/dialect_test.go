@@ -0,0 +1,106 @@
+package smb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// negotiateResponse builds the start of an SMB2 negotiate response.
+func negotiateResponse(status uint32, revision uint16) []byte {
+	header := make([]byte, 64)
+	copy(header, "\xfeSMB")
+	binary.LittleEndian.PutUint32(header[8:12], status)
+	body := make([]byte, 65)
+	binary.LittleEndian.PutUint16(body[0:2], 65)
+	binary.LittleEndian.PutUint16(body[4:6], revision)
+
+	msg := append(header, body...)
+	transport := make([]byte, 4)
+	binary.BigEndian.PutUint32(transport, uint32(len(msg)))
+	return append(transport, msg...)
+}
+
+func TestDialect(t *testing.T) {
+	cases := []struct {
+		name     string
+		response []byte
+		chunk    int
+		want     string
+	}{
+		{"2.0.2", negotiateResponse(0, 0x0202), 4096, "2.0.2"},
+		{"2.1", negotiateResponse(0, 0x0210), 4096, "2.1"},
+		{"3.0", negotiateResponse(0, 0x0300), 4096, "3.0"},
+		{"3.0.2", negotiateResponse(0, 0x0302), 4096, "3.0.2"},
+		{"3.1.1", negotiateResponse(0, 0x0311), 4096, "3.1.1"},
+		{"SmallReads", negotiateResponse(0, 0x0311), 5, "3.1.1"},
+		{"Unknown", negotiateResponse(0, 0x02ff), 4096, "0x2ff"},
+		{"Error", negotiateResponse(0xc0000022, 0x0311), 4096, ""},
+		{"NotSMB2", append([]byte{0, 0, 0, 70, 0xff, 'S', 'M', 'B'}, make([]byte, 100)...), 4096, ""},
+		{"Short", negotiateResponse(0, 0x0311)[:40], 4096, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				_, _ = server.Write(c.response)
+				server.Close()
+			}()
+
+			// The client reads the whole stream, in chunks of any size
+			conn := &dialectConn{Conn: client}
+			var got bytes.Buffer
+			buf := make([]byte, c.chunk)
+			for {
+				n, err := conn.Read(buf)
+				got.Write(buf[:n])
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !bytes.Equal(got.Bytes(), c.response) {
+				t.Error("dialectConn changed the data read from the server")
+			}
+			if dialect := conn.dialect(); dialect != c.want {
+				t.Errorf("dialect() = %q, want %q", dialect, c.want)
+			}
+		})
+	}
+}
+
+func TestDefinitionErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		def  Definition
+	}{
+		{"Regex", Definition{ContentRegex: "("}},
+		{"Dialect", Definition{Dialect: "1.0"}},
+		{"NTHash", Definition{NTHash: "31d6cfe0d16ae931b73c59d7e0c089"}},
+		{"NTHashHex", Definition{NTHash: "zzd6cfe0d16ae931b73c59d7e0c089c0"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host, d.Port = "127.0.0.1", "0"
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+
+			r := d.Run(context.Background())
+			if r.Failure != check.DefinitionError {
+				t.Errorf("Failure = %q, want %q: %s", r.Failure, check.DefinitionError, r.Message)
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package smb
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hirochachacha/go-smb2"
@@ -13,18 +17,36 @@ import (
 	"go.uber.org/zap"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "smb",
+		Description: "Read, write, and list files on an SMB share",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// maxFile is the most data that will be read from a file on the share.
+const maxFile = 10 * 1024 * 1024
+
 // The Definition configures the behavior of the SMB check
 // it implements the "check" interface
 type Definition struct {
-	Config       check.Config // generic metadata about the check
-	Host         string       `optiontype:"required"`                     // IP or hostname for SMB server
-	Username     string       `optiontype:"required"`                     // Username for SMB share
-	Password     string       `optiontype:"required"`                     // Password for SMB user
-	Share        string       `optiontype:"required"`                     // Name of the share
-	Domain       string       `optiontype:"required"`                     // The domain found in front of a login (SMB\Administrator : SMB would be the domain)
-	File         string       `optiontype:"required"`                     // The file in the SMB share
-	ContentRegex string       `optiontype:"optional" optiondefault:".*"`  // Regex to match on
-	Port         string       `optiontype:"optional" optiondefault:"445"` // Port of the server
+	Config          check.Config // generic metadata about the check
+	Host            string       `optiontype:"required"`                     // IP or hostname for SMB server
+	Username        string       `optiontype:"required"`                     // Username for SMB share
+	Password        string       `optiontype:"optional"`                     // Password for SMB user
+	NTHash          string       `optiontype:"optional"`                     // NT hash of the user's password in hex, used instead of the password
+	Share           string       `optiontype:"required"`                     // Name of the share
+	Domain          string       `optiontype:"optional"`                     // The domain found in front of a login (SMB\Administrator : SMB would be the domain)
+	File            string       `optiontype:"optional"`                     // The file in the SMB share to read
+	ContentRegex    string       `optiontype:"optional" optiondefault:".*"`  // Regex the file's contents must match
+	Hash            string       `optiontype:"optional"`                     // The sha256 hash the file's contents must have
+	Directory       string       `optiontype:"optional"`                     // Directory in the SMB share to list, or \ for the root of the share
+	Entries         []string     `optiontype:"optional"`                     // Names that must be in the directory
+	WriteFile       string       `optiontype:"optional"`                     // File in the SMB share to write a random token to, read back, and delete
+	Dialect         string       `optiontype:"optional"`                     // SMB dialect to require: 2.0.2, 2.1, 3.0, 3.0.2, or 3.1.1
+	SigningRequired string       `optiontype:"optional"`                     // Whether messages must be signed
+	Port            string       `optiontype:"optional" optiondefault:"445"` // Port of the server
 }
 
 // Run a single instance of the check
@@ -32,29 +54,75 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Initialize empty result
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
-	// Dial SMB server
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", d.Host, d.Port))
+	// Validate the definition before connecting
+	regex, err := regexp.Compile(d.ContentRegex)
 	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	var dialect uint16
+	if d.Dialect != "" {
+		var ok bool
+		dialect, ok = dialects[d.Dialect]
+		if !ok {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Invalid Dialect '%s' : must be one of 2.0.2, 2.1, 3.0, 3.0.2, or 3.1.1", d.Dialect)
+			return result
+		}
+	}
+	var hash []byte
+	if d.NTHash != "" {
+		hash, err = hex.DecodeString(d.NTHash)
+		if err != nil || len(hash) != 16 {
+			result.Failure = check.DefinitionError
+			result.Message = "NTHash must be 32 hex characters"
+			return result
+		}
+	}
+	signingRequired, _ := strconv.ParseBool(d.SigningRequired)
+
+	// Dial SMB server. The connection's deadline makes sure reads and writes
+	// on the share obey the check's deadline.
+	start := time.Now()
+	tcpConn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Error with initial dial : %s", err)
 		return result
 	}
+	result.Time("connect", start)
+	conn := &dialectConn{Conn: tcpConn}
 	defer conn.Close()
 
 	// Configure SMB dialer
 	smbConn := &smb2.Dialer{
+		Negotiator: smb2.Negotiator{
+			RequireMessageSigning: signingRequired,
+			SpecifiedDialect:      dialect,
+		},
+		// The SMB library only supports NTLM, and its Initiator interface
+		// can't be implemented outside of it, so Kerberos isn't available
 		Initiator: &smb2.NTLMInitiator{
 			User:     d.Username,
 			Password: d.Password,
+			Hash:     hash,
 			Domain:   d.Domain,
 		},
 	}
 
 	// Dial SMB server for SMB connection
+	start = time.Now()
 	c, err := smbConn.DialContext(ctx, conn)
+	if negotiated := conn.dialect(); negotiated != "" {
+		result.Details = map[string]string{"dialect": negotiated}
+	}
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Error connecting to smb server : %s", err)
 		return result
 	}
+	result.Time("auth", start)
 	defer func() {
 		err := c.Logoff()
 		if err != nil {
@@ -65,6 +133,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Mount the SMB share
 	fs, err := c.Mount(fmt.Sprintf(`\\%s\%s`, d.Host, d.Share))
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Error mounting share : %s", err)
 		return result
 	}
@@ -75,44 +144,116 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		}
 	}()
 
-	// Open the file for reading
-	f, err := fs.Open(d.File)
-	if err != nil {
-		result.Message = fmt.Sprintf("Error opening file : %s", err)
-		return result
+	start = time.Now()
+	if d.Directory != "" {
+		failure, err := d.list(fs)
+		if err != nil {
+			result.Failure = failure
+			result.Message = fmt.Sprintf("Error listing directory %s : %s", d.Directory, err)
+			return result
+		}
 	}
-	defer f.Close()
+	if d.File != "" {
+		content, err := read(fs, d.File)
+		if err != nil {
+			result.Failure = check.Classify(err, check.ContentMismatch)
+			result.Message = fmt.Sprintf("Error reading file : %s", err)
+			return result
+		}
 
-	// Ensure we are reading from the beginning of the file
-	_, err = f.Seek(0, io.SeekStart)
+		// Check if content matches regex
+		if !regex.Match(content) {
+			result.Failure = check.ContentMismatch
+			result.Message = "Matching content not found"
+			return result
+		}
+		if d.Hash != "" {
+			digest := sha256.Sum256(content)
+			if hash := hex.EncodeToString(digest[:]); hash != strings.ToLower(d.Hash) {
+				result.Failure = check.ContentMismatch
+				result.Message = fmt.Sprintf("File has incorrect hash %s", hash)
+				return result
+			}
+		}
+	}
+	if d.WriteFile != "" {
+		failure, err := write(fs, d.WriteFile)
+		if err != nil {
+			result.Failure = failure
+			result.Message = fmt.Sprintf("Error verifying write to %s : %s", d.WriteFile, err)
+			return result
+		}
+	}
+	result.Time("share", start)
+
+	// If we reach here the check is successful
+	result.Passed = true
+	return result
+}
+
+// list makes sure the directory contains all the expected entries.
+func (d *Definition) list(fs *smb2.Share) (check.Failure, error) {
+	entries, err := fs.ReadDir(strings.Trim(d.Directory, `\/`))
 	if err != nil {
-		result.Message = fmt.Sprintf("Error seeking to beginning of file : %s", err)
-		return result
+		return check.Classify(err, check.ContentMismatch), err
 	}
 
-	// Read from the file
-	content, err := io.ReadAll(f)
+	for _, expected := range d.Entries {
+		found := false
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return check.ContentMismatch, fmt.Errorf("%s not found", expected)
+		}
+	}
+
+	return check.None, nil
+}
+
+// read returns the contents of a file on the share.
+func read(fs *smb2.Share, name string) ([]byte, error) {
+	f, err := fs.Open(name)
 	if err != nil {
-		result.Message = fmt.Sprintf("Error reading the file contents : %s", err)
-		return result
+		return nil, err
 	}
+	defer f.Close()
 
-	// Compile regex
-	regex, err := regexp.Compile(d.ContentRegex)
+	return io.ReadAll(io.LimitReader(f, maxFile))
+}
+
+// write writes a random token to a file on the share, reads it back, and
+// removes the file.
+func write(fs *smb2.Share, name string) (check.Failure, error) {
+	token := check.Token()
+
+	f, err := fs.Create(name)
 	if err != nil {
-		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
-		return result
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not create file: %w", err)
+	}
+	defer fs.Remove(name) //nolint:errcheck
+	_, err = f.Write([]byte(token))
+	if err != nil {
+		f.Close()
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not write file: %w", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not write file: %w", err)
 	}
 
-	// Check if content matches regex
-	if !regex.Match(content) {
-		result.Message = "Matching content not found"
-		return result
+	content, err := read(fs, name)
+	if err != nil {
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not read file: %w", err)
+	}
+	if string(content) != token {
+		return check.ContentMismatch, fmt.Errorf("contents read back did not match what was written")
 	}
 
-	// If we reach here the check is successful
-	result.Passed = true
-	return result
+	return check.None, nil
 }
 
 // GetConfig returns the current CheckConfig struct this check has been
@@ -13,6 +13,14 @@ import (
 	"go.uber.org/zap"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "smtp",
+		Description: "Send an email through an SMTP server",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the SMTP check
 // it implements the "check" interface
 type Definition struct {
@@ -45,12 +53,6 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Initialize empty result
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
-	// Create a dialer
-	// TODO: change this to be relative to the parent context's timeout
-	dialer := net.Dialer{
-		Timeout: 20 * time.Second,
-	}
-
 	// ***********************************************
 	// Set up custom auth for bypassing net/smtp protections
 	auth := unencryptedAuth{smtp.PlainAuth("", d.Username, d.Password, d.Host)}
@@ -63,16 +65,11 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		InsecureSkipVerify: true,
 	}
 
-	// Declare these for the below if block
-	var conn net.Conn
-	var err error
-
-	if encrypted, _ := strconv.ParseBool(d.Encrypted); encrypted {
-		conn, err = tls.DialWithDialer(&dialer, "tcp", fmt.Sprintf("%s:%s", d.Host, d.Port), &tlsConfig)
-	} else {
-		conn, err = dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%s", d.Host, d.Port))
-	}
+	// Connect to the server. The connection's deadline makes sure the SMTP
+	// conversation obeys the check's deadline.
+	conn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Connecting to server %s failed : %s", d.Host, err)
 		return result
 	}
@@ -83,9 +80,21 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		}
 	}()
 
+	if encrypted, _ := strconv.ParseBool(d.Encrypted); encrypted {
+		tlsConn := tls.Client(conn, &tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			result.Failure = check.Classify(err, check.TLS)
+			result.Message = fmt.Sprintf("Connecting to server %s failed : %s", d.Host, err)
+			return result
+		}
+		conn = tlsConn
+	}
+
 	// Create smtp client
 	c, err := smtp.NewClient(conn, d.Host)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Created smtp client to host %s failed : %s", d.Host, err)
 		return result
 	}
@@ -99,6 +108,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Login
 	err = c.Auth(auth)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Login to %s failed : %s", d.Host, err)
 		return result
 	}
@@ -106,6 +116,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Set the sender
 	err = c.Mail(d.Sender)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Setting sender %s failed : %s", d.Sender, err)
 		return result
 	}
@@ -113,6 +124,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Set the reciver
 	err = c.Rcpt(d.Reciever)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Setting reciever %s failed : %s", d.Reciever, err)
 		return result
 	}
@@ -120,6 +132,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Send the email body.
 	wc, err := c.Data()
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Creating writer failed : %s", err)
 		return result
 	}
@@ -128,6 +141,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Write the body
 	_, err = fmt.Fprintf(wc, d.Body)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Writing mail body failed : %s", err)
 		return result
 	}
@@ -0,0 +1,52 @@
+package sqldb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// countSyntax matches an expected row count, like 3, >0, or <=10.
+var countSyntax = regexp.MustCompile(`^\s*(=|==|!=|>=|<=|>|<)?\s*(\d+)\s*$`)
+
+// A comparison checks a number of rows against an expected count.
+type comparison struct {
+	op    string
+	count int
+}
+
+// parseCount parses an expected row count. If the count is empty, nil is
+// returned.
+func parseCount(s string) (*comparison, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	m := countSyntax.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("must be a number, optionally after one of =, !=, >, >=, <, or <=")
+	}
+	count, err := strconv.Atoi(m[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparison{op: m[1], count: count}, nil
+}
+
+func (c *comparison) check(n int) bool {
+	switch c.op {
+	case "!=":
+		return n != c.count
+	case ">":
+		return n > c.count
+	case ">=":
+		return n >= c.count
+	case "<":
+		return n < c.count
+	case "<=":
+		return n <= c.count
+	default:
+		return n == c.count
+	}
+}
@@ -0,0 +1,48 @@
+package sqldb
+
+import "testing"
+
+func TestParseCount(t *testing.T) {
+	cases := []struct {
+		count string
+		pass  []int
+		fail  []int
+		err   bool
+	}{
+		{"3", []int{3}, []int{2, 4}, false},
+		{"=3", []int{3}, []int{0}, false},
+		{"== 3", []int{3}, []int{0}, false},
+		{"!=0", []int{1, 5}, []int{0}, false},
+		{">0", []int{1, 100}, []int{0}, false},
+		{" >= 2 ", []int{2, 3}, []int{1}, false},
+		{"<10", []int{0, 9}, []int{10}, false},
+		{"<=10", []int{10}, []int{11}, false},
+		{"", nil, nil, false},
+		{"many", nil, nil, true},
+		{"=>3", nil, nil, true},
+		{"-1", nil, nil, true},
+		{"99999999999999999999", nil, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.count, func(t *testing.T) {
+			cmp, err := parseCount(c.count)
+			if (err != nil) != c.err {
+				t.Fatalf("parseCount() error = %v, want error: %t", err, c.err)
+			}
+			if c.count == "" && cmp != nil {
+				t.Errorf("parseCount() = %+v, want nil", cmp)
+			}
+			for _, n := range c.pass {
+				if !cmp.check(n) {
+					t.Errorf("%d rows did not satisfy %s", n, c.count)
+				}
+			}
+			for _, n := range c.fail {
+				if cmp.check(n) {
+					t.Errorf("%d rows satisfied %s", n, c.count)
+				}
+			}
+		})
+	}
+}
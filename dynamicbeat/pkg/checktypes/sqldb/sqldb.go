@@ -0,0 +1,346 @@
+// Package sqldb implements the checks for SQL databases. Each database is
+// supported by an Engine, which knows how to connect to it, and all of the
+// querying and validation is shared between them.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// maxRows is the most rows that will be read from the results of a query.
+const maxRows = 10000
+
+// identifier matches the table and column names that can be put into queries
+// built by the check. Names are checked because they can't be bound as
+// parameters.
+var identifier = regexp.MustCompile(`^[\w.]+$`)
+
+// An Engine connects to one kind of SQL database.
+type Engine struct {
+	File        bool                                                         // whether the database is a local file instead of a server
+	Port        string                                                       // the port the database listens on by default
+	Open        func(d *Definition, timer *check.DialTimer) (*sql.DB, error) // creates a handle to the database that dials with the timer
+	Placeholder func(n int) string                                           // returns the placeholder for the nth parameter of a query, starting at 1
+}
+
+// The Definition configures the behavior of the SQL checks
+// it implements the "check" interface
+type Definition struct {
+	Config       check.Config // generic metadata about the check
+	Host         string       `optiontype:"optional"`                    // IP or Hostname for the database server
+	Username     string       `optiontype:"optional"`                    // Username for the database
+	Password     string       `optiontype:"optional"`                    // Password for the user
+	Database     string       `optiontype:"required"`                    // Name of the database to access, or the path to its file in files_dir
+	Port         string       `optiontype:"optional"`                    // Port for the server, if it isn't the database's default
+	TLS          string       `optiontype:"optional"`                    // TLS mode: disable, require, or verify-full
+	CABundle     string       `optiontype:"optional"`                    // PEM-encoded CA certificates to validate the server against with verify-full
+	Query        string       `optiontype:"optional"`                    // Query to run, using the database's placeholders for parameters
+	Params       []string     `optiontype:"optional"`                    // Values to bind to the query's parameters
+	Table        string       `optiontype:"optional"`                    // Name of the table to query, if Query isn't set
+	Column       string       `optiontype:"optional"`                    // Name of the column to query if Query isn't set, or to match ContentRegex against
+	MatchContent string       `optiontype:"optional"`                    // Whether to perform a regex content match on the results of the query
+	ContentRegex string       `optiontype:"optional" optiondefault:".*"` // Regex that at least one row must match
+	RowCount     string       `optiontype:"optional"`                    // Expected number of rows, optionally with a comparison like >0
+	Cells        []*Cell      `optiontype:"list"`                        // Values that specific cells must have
+	WriteTable   string       `optiontype:"optional"`                    // Table to insert, read back, and delete a random token in
+	WriteColumn  string       `optiontype:"optional"`                    // Column of WriteTable to store the token in
+
+	engine *Engine // the engine for the registered check type
+}
+
+// A Cell is a single value in the results of the query.
+type Cell struct {
+	Row    int    `optiontype:"required"` // Number of the row, starting at 1
+	Column string `optiontype:"required"` // Name of the column
+	Value  string `optiontype:"optional"` // The value the cell must have, or NULL
+}
+
+// New creates an empty definition for a check type that uses an engine.
+func New(engine *Engine) *Definition {
+	return &Definition{engine: engine}
+}
+
+type timerKey struct{}
+
+// Timer returns the DialTimer for the check that a connection is being opened
+// for, for drivers that can only be given a dialer globally.
+func Timer(ctx context.Context) *check.DialTimer {
+	timer, ok := ctx.Value(timerKey{}).(*check.DialTimer)
+	if !ok {
+		return &check.DialTimer{}
+	}
+
+	return timer
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	// Validate the definition before connecting
+	if !d.engine.File && (d.Host == "" || d.Username == "") {
+		result.Failure = check.DefinitionError
+		result.Message = "Host and Username are required"
+		return result
+	}
+	_, err := d.TLSConfig()
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = err.Error()
+		return result
+	}
+	query, err := d.query()
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = err.Error()
+		return result
+	}
+	count, err := parseCount(d.RowCount)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid RowCount '%s' : %s", d.RowCount, err)
+		return result
+	}
+	for _, cell := range d.Cells {
+		if cell.Row < 1 {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Invalid Row %d for column %s : rows start at 1", cell.Row, cell.Column)
+			return result
+		}
+	}
+	matchContent, _ := strconv.ParseBool(d.MatchContent)
+	regex, err := regexp.Compile(d.ContentRegex)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+		return result
+	}
+	if (d.WriteTable == "") != (d.WriteColumn == "") || (d.WriteTable != "" && !(identifier.MatchString(d.WriteTable) && identifier.MatchString(d.WriteColumn))) {
+		result.Failure = check.DefinitionError
+		result.Message = "WriteTable and WriteColumn must both be set to valid table and column names"
+		return result
+	}
+	if d.Port == "" {
+		d.Port = d.engine.Port
+	}
+
+	// Create DB handle
+	timer := &check.DialTimer{}
+	db, err := d.engine.Open(d, timer)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Creating database handle failed : %s", err)
+		return result
+	}
+	defer db.Close()
+
+	// Set connection parameters
+	db.SetMaxIdleConns(-1)
+	db.SetMaxOpenConns(1)
+
+	// Check db connection
+	start := time.Now()
+	err = db.PingContext(context.WithValue(ctx, timerKey{}, timer))
+	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
+		result.Message = fmt.Sprintf("Failed to ping database : %s", err)
+		return result
+	}
+	result.Record("connect", timer.Took())
+	result.Record("auth", time.Since(start)-timer.Took())
+
+	// Query the DB
+	if query != "" {
+		start = time.Now()
+		columns, rows, err := fetch(ctx, db, query, d.Params)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Could not query database : %s", err)
+			return result
+		}
+		result.Time("query", start)
+
+		failure, err := d.validate(columns, rows, count, matchContent, regex, &result)
+		if err != nil {
+			result.Failure = failure
+			result.Message = err.Error()
+			return result
+		}
+	}
+
+	// Make sure the database can store data
+	if d.WriteTable != "" {
+		start = time.Now()
+		err = d.write(ctx, db)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Write verification failed : %s", err)
+			return result
+		}
+		result.Time("write", start)
+	}
+
+	// Check passes if we reach here
+	result.Passed = true
+	return result
+}
+
+// query returns the query to run. Older checks only set a table and column,
+// so a query is built from them.
+func (d *Definition) query() (string, error) {
+	if d.Query != "" || d.Table == "" {
+		return d.Query, nil
+	}
+
+	if !identifier.MatchString(d.Table) || (d.Column != "*" && !identifier.MatchString(d.Column)) {
+		return "", fmt.Errorf("Invalid table or column name : use Query for anything more complex than a name")
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", d.Column, d.Table), nil
+}
+
+// fetch runs a query and returns the names of the columns and the values of
+// each row. NULL values are returned as the string NULL.
+func fetch(ctx context.Context, db *sql.DB, query string, params []string) ([]string, [][]string, error) {
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		args[i] = p
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values [][]string
+	for rows.Next() && len(values) < maxRows {
+		cells := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range cells {
+			dest[i] = &cells[i]
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not scan row values: %w", err)
+		}
+
+		row := make([]string, len(columns))
+		for i, cell := range cells {
+			if cell.Valid {
+				row[i] = cell.String
+			} else {
+				row[i] = "NULL"
+			}
+		}
+		values = append(values, row)
+	}
+
+	return columns, values, rows.Err()
+}
+
+// validate checks the results of the query against the definition, and
+// records how many rows were returned and matched.
+func (d *Definition) validate(columns []string, rows [][]string, count *comparison, matchContent bool, regex *regexp.Regexp, result *check.Result) (check.Failure, error) {
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[strings.ToLower(c)] = i
+	}
+
+	// Find the rows that match the regex
+	column := -1
+	if matchContent && d.Query != "" && d.Column != "" {
+		i, ok := index[strings.ToLower(d.Column)]
+		if !ok {
+			return check.DefinitionError, fmt.Errorf("Query results do not have a %s column", d.Column)
+		}
+		column = i
+	}
+	matched := 0
+	for _, row := range rows {
+		if !matchContent {
+			matched++
+			continue
+		}
+		for i, value := range row {
+			if (column < 0 || i == column) && regex.MatchString(value) {
+				matched++
+				break
+			}
+		}
+	}
+	result.Details = map[string]string{
+		"rows":         strconv.Itoa(len(rows)),
+		"matched_rows": strconv.Itoa(matched),
+	}
+
+	if matchContent && matched == 0 {
+		return check.ContentMismatch, fmt.Errorf("No rows matched %s", d.ContentRegex)
+	}
+	if count != nil && !count.check(len(rows)) {
+		return check.ContentMismatch, fmt.Errorf("Query returned %d rows, expected %s", len(rows), d.RowCount)
+	}
+	for _, cell := range d.Cells {
+		i, ok := index[strings.ToLower(cell.Column)]
+		if !ok {
+			return check.DefinitionError, fmt.Errorf("Query results do not have a %s column", cell.Column)
+		}
+		if cell.Row > len(rows) {
+			return check.ContentMismatch, fmt.Errorf("Query returned %d rows, so there is no row %d", len(rows), cell.Row)
+		}
+		if value := rows[cell.Row-1][i]; value != cell.Value {
+			return check.ContentMismatch, fmt.Errorf("Row %d of column %s is %s, expected %s", cell.Row, cell.Column, value, cell.Value)
+		}
+	}
+
+	return check.None, nil
+}
+
+// write inserts a random token into the write table, reads it back, and
+// deletes it.
+func (d *Definition) write(ctx context.Context, db *sql.DB) error {
+	token := check.Token()
+	p := d.engine.Placeholder(1)
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", d.WriteTable, d.WriteColumn, p), token)
+	if err != nil {
+		return fmt.Errorf("could not insert token: %w", err)
+	}
+
+	var value string
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", d.WriteColumn, d.WriteTable, d.WriteColumn, p), token).Scan(&value)
+	if err != nil {
+		return fmt.Errorf("could not read token back: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s = %s", d.WriteTable, d.WriteColumn, p), token)
+	if err != nil {
+		return fmt.Errorf("could not delete token: %w", err)
+	}
+
+	return nil
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
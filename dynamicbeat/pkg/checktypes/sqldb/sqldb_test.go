@@ -0,0 +1,136 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+
+	// SQLite driver
+	_ "modernc.org/sqlite"
+)
+
+// database creates a SQLite database with a small table of users, and returns
+// an engine that opens it.
+func database(t *testing.T) *Engine {
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT)",
+		"INSERT INTO users (name, email) VALUES ('admin', 'admin@team01.local'), ('alice', NULL), ('bob', 'bob@team01.local')",
+		"CREATE TABLE tokens (value TEXT)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &Engine{
+		File: true,
+		Open: func(d *Definition, _ *check.DialTimer) (*sql.DB, error) {
+			return sql.Open("sqlite", path)
+		},
+		Placeholder: func(int) string { return "?" },
+	}
+}
+
+func TestQuery(t *testing.T) {
+	cases := []struct {
+		name  string
+		def   Definition
+		query string
+		err   bool
+	}{
+		{"Query", Definition{Query: "SELECT 1", Table: "users"}, "SELECT 1", false},
+		{"Column", Definition{Table: "users", Column: "name"}, "SELECT name FROM users", false},
+		{"Star", Definition{Table: "app.users", Column: "*"}, "SELECT * FROM app.users", false},
+		{"None", Definition{}, "", false},
+		{"Table", Definition{Table: "users; DROP TABLE users", Column: "name"}, "", true},
+		{"BadColumn", Definition{Table: "users", Column: "name FROM secrets --"}, "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query, err := c.def.query()
+			if (err != nil) != c.err {
+				t.Fatalf("query() error = %v, want error: %t", err, c.err)
+			}
+			if query != c.query {
+				t.Errorf("query() = %q, want %q", query, c.query)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	engine := database(t)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		details map[string]string
+	}{
+		{"Table", Definition{Table: "users", Column: "name", MatchContent: "true", ContentRegex: "^admin$"}, true, check.None, map[string]string{"rows": "3", "matched_rows": "1"}},
+		{"Params", Definition{Query: "SELECT name FROM users WHERE name = ? OR name = ?", Params: []string{"alice", "bob"}, RowCount: "2"}, true, check.None, nil},
+		{"RowCount", Definition{Query: "SELECT * FROM users", RowCount: ">=5"}, false, check.ContentMismatch, nil},
+		{"Column", Definition{Query: "SELECT name, email FROM users", Column: "email", MatchContent: "true", ContentRegex: "^bob@"}, true, check.None, map[string]string{"matched_rows": "1"}},
+		{"WrongColumn", Definition{Query: "SELECT name, email FROM users", Column: "EMAIL", MatchContent: "true", ContentRegex: "^alice"}, false, check.ContentMismatch, nil},
+		{"MissingColumn", Definition{Query: "SELECT name FROM users", Column: "phone", MatchContent: "true"}, false, check.DefinitionError, nil},
+		{"Cells", Definition{Query: "SELECT name, email FROM users ORDER BY id", Cells: []*Cell{
+			{Row: 1, Column: "Name", Value: "admin"},
+			{Row: 2, Column: "email", Value: "NULL"},
+		}}, true, check.None, nil},
+		{"WrongCell", Definition{Query: "SELECT name FROM users ORDER BY id", Cells: []*Cell{{Row: 3, Column: "name", Value: "carol"}}}, false, check.ContentMismatch, nil},
+		{"MissingRow", Definition{Query: "SELECT name FROM users", Cells: []*Cell{{Row: 4, Column: "name", Value: "carol"}}}, false, check.ContentMismatch, nil},
+		{"MissingCellColumn", Definition{Query: "SELECT name FROM users", Cells: []*Cell{{Row: 1, Column: "id"}}}, false, check.DefinitionError, nil},
+		{"RowZero", Definition{Query: "SELECT name FROM users", Cells: []*Cell{{Row: 0, Column: "name"}}}, false, check.DefinitionError, nil},
+		{"Write", Definition{WriteTable: "tokens", WriteColumn: "value"}, true, check.None, nil},
+		{"WriteColumn", Definition{WriteTable: "tokens"}, false, check.DefinitionError, nil},
+		{"WriteTable", Definition{WriteTable: "tokens t", WriteColumn: "value"}, false, check.DefinitionError, nil},
+		{"MissingTable", Definition{WriteTable: "secrets", WriteColumn: "value"}, false, check.Protocol, nil},
+		{"BadQuery", Definition{Query: "SELEC name FROM users"}, false, check.Protocol, nil},
+		{"BadRowCount", Definition{Query: "SELECT 1", RowCount: "some"}, false, check.DefinitionError, nil},
+		{"Regex", Definition{Query: "SELECT 1", MatchContent: "true", ContentRegex: "("}, false, check.DefinitionError, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.engine = engine
+			if d.ContentRegex == "" {
+				d.ContentRegex = ".*"
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			for k, v := range c.details {
+				if r.Details[k] != v {
+					t.Errorf("Details[%s] = %q, want %q", k, r.Details[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRequiresHost(t *testing.T) {
+	d := New(&Engine{Port: "5432"})
+	d.Database = "app"
+	r := d.Run(context.Background())
+	if r.Failure != check.DefinitionError {
+		t.Errorf("Failure = %q, want %q: %s", r.Failure, check.DefinitionError, r.Message)
+	}
+}
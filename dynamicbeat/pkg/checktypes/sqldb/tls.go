@@ -0,0 +1,36 @@
+package sqldb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// TLS modes that can be used with any engine.
+const (
+	TLSDisable    = "disable"     // never use TLS
+	TLSRequire    = "require"     // use TLS without validating the server's certificate
+	TLSVerifyFull = "verify-full" // use TLS and validate the server's certificate and hostname
+)
+
+// TLSConfig returns the TLS configuration for connecting to the database, or
+// nil if TLS is disabled or the engine's default should be used.
+func (d *Definition) TLSConfig() (*tls.Config, error) {
+	switch d.TLS {
+	case "", TLSDisable:
+		return nil, nil
+	case TLSRequire:
+		return &tls.Config{InsecureSkipVerify: true}, nil //nolint:gosec
+	case TLSVerifyFull:
+		config := &tls.Config{ServerName: d.Host}
+		if d.CABundle != "" {
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM([]byte(d.CABundle)) {
+				return nil, fmt.Errorf("CABundle does not contain any PEM-encoded certificates")
+			}
+		}
+		return config, nil
+	default:
+		return nil, fmt.Errorf("Invalid TLS mode '%s' : must be one of disable, require, or verify-full", d.TLS)
+	}
+}
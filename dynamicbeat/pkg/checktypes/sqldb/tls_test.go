@@ -0,0 +1,75 @@
+package sqldb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestTLSConfig(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Team 01 CA"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	cases := []struct {
+		name     string
+		mode     string
+		bundle   string
+		enabled  bool
+		verified bool
+		roots    bool
+		err      bool
+	}{
+		{"Default", "", "", false, false, false, false},
+		{"Disable", TLSDisable, "", false, false, false, false},
+		{"Require", TLSRequire, "", true, false, false, false},
+		{"VerifyFull", TLSVerifyFull, "", true, true, false, false},
+		{"CABundle", TLSVerifyFull, bundle, true, true, true, false},
+		{"InvalidBundle", TLSVerifyFull, "not a certificate", false, false, false, true},
+		{"Unknown", "prefer", "", false, false, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Definition{Host: "db.team01.local", TLS: c.mode, CABundle: c.bundle}
+			config, err := d.TLSConfig()
+			if (err != nil) != c.err {
+				t.Fatalf("TLSConfig() error = %v, want error: %t", err, c.err)
+			}
+			if (config != nil) != c.enabled {
+				t.Fatalf("TLSConfig() = %+v, want TLS enabled: %t", config, c.enabled)
+			}
+			if config == nil {
+				return
+			}
+			if config.InsecureSkipVerify == c.verified {
+				t.Errorf("InsecureSkipVerify = %t, want %t", config.InsecureSkipVerify, !c.verified)
+			}
+			if c.verified && config.ServerName != "db.team01.local" {
+				t.Errorf("ServerName = %s, want db.team01.local", config.ServerName)
+			}
+			if (config.RootCAs != nil) != c.roots {
+				t.Errorf("RootCAs set = %t, want %t", config.RootCAs != nil, c.roots)
+			}
+		})
+	}
+}
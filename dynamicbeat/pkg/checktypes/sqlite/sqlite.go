@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/sqldb"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
+
+	// SQLite driver
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "sqlite",
+		Description: "Query a SQLite database file",
+		New:         func() check.Check { return sqldb.New(engine) },
+	})
+}
+
+var engine = &sqldb.Engine{
+	File:        true,
+	Open:        open,
+	Placeholder: func(int) string { return "?" },
+}
+
+func open(d *sqldb.Definition, _ *check.DialTimer) (*sql.DB, error) {
+	// The database is a file in files_dir, which must already exist
+	path, err := files.Path(d.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.Open("sqlite", (&url.URL{Scheme: "file", Opaque: path, RawQuery: "mode=rw"}).String())
+}
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/sqldb"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(dir, "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("CREATE TABLE products (name TEXT); INSERT INTO products VALUES ('widget')")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Configure(dir)
+	defer files.Configure("")
+
+	cases := []struct {
+		name     string
+		database string
+		passed   bool
+		failure  check.Failure
+	}{
+		{"Database", "store.db", true, check.None},
+		{"Escape", "../store.db", true, check.None},
+		{"Missing", "missing.db", false, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := sqldb.New(engine)
+			d.Database = c.database
+			d.Query, d.RowCount = "SELECT name FROM products", "1"
+			d.ContentRegex = ".*"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+		})
+	}
+}
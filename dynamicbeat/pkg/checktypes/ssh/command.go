@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+// A Command is run in its own session, and must exit with the expected code
+// and produce the expected output.
+type Command struct {
+	Cmd         string `optiontype:"required"` // The command to execute
+	ExitCode    int    `optiontype:"optional"` // The exit code the command must return
+	StdoutRegex string `optiontype:"optional"` // Regex the command's stdout must match
+	StderrRegex string `optiontype:"optional"` // Regex the command's stderr must match
+}
+
+// run executes the command and checks its results. The exit code is returned
+// if the command finished, or -1 if it didn't.
+func (c *Command) run(client *ssh.Client) (int, check.Failure, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return -1, check.Classify(err, check.Protocol), fmt.Errorf("error creating a ssh session: %w", err)
+	}
+	defer closeSession(session)
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	code := 0
+	err = session.Run(c.Cmd)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitStatus()
+	} else if err != nil {
+		return -1, check.Classify(err, check.Protocol), err
+	}
+
+	if code != c.ExitCode {
+		return code, check.ContentMismatch, fmt.Errorf("exited with code %d, expected %d", code, c.ExitCode)
+	}
+	if failure, err := match(c.StdoutRegex, stdout.Bytes()); err != nil {
+		return code, failure, fmt.Errorf("stdout %w", err)
+	}
+	if failure, err := match(c.StderrRegex, stderr.Bytes()); err != nil {
+		return code, failure, fmt.Errorf("stderr %w", err)
+	}
+
+	return code, check.None, nil
+}
+
+// combinedOutput runs a command and returns its combined stdout and stderr.
+// Commands that exit with a nonzero code return an error.
+func combinedOutput(client *ssh.Client, cmd string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error creating a ssh session: %w", err)
+	}
+	defer closeSession(session)
+
+	return session.CombinedOutput(cmd)
+}
+
+// match checks output against a regex, if one is given.
+func match(pattern string, output []byte) (check.Failure, error) {
+	if pattern == "" {
+		return check.None, nil
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return check.DefinitionError, fmt.Errorf("regex %s could not be compiled: %w", pattern, err)
+	}
+	if !regex.Match(output) {
+		return check.ContentMismatch, fmt.Errorf("did not match %s", pattern)
+	}
+
+	return check.None, nil
+}
+
+func closeSession(session *ssh.Session) {
+	err := session.Close()
+	if err != nil && err.Error() != "EOF" {
+		zap.S().Warnf("Failed to close SSH session connection: %s", err)
+	}
+}
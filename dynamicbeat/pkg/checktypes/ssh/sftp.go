@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// maxFile is the most data that will be read from a file over SFTP.
+const maxFile = 10 * 1024 * 1024
+
+// A File is read, or written and read back, over SFTP, and its contents are
+// checked.
+type File struct {
+	Path         string `optiontype:"required"`                       // Path to the file on the server
+	Write        string `optiontype:"optional" optiondefault:"false"` // Whether to write the file before reading it back
+	Content      string `optiontype:"optional"`                       // Contents to write to the file, or a random token if empty
+	Keep         string `optiontype:"optional" optiondefault:"false"` // Whether to leave a written file on the server instead of removing it
+	ContentRegex string `optiontype:"optional"`                       // Regex the file's contents must match
+	Hash         string `optiontype:"optional"`                       // The sha256 hash the file's contents must have
+}
+
+// check reads the file and compares it to what it's expected to contain.
+func (f *File) check(client *sftp.Client) (check.Failure, error) {
+	write, _ := strconv.ParseBool(f.Write)
+	keep, _ := strconv.ParseBool(f.Keep)
+
+	// Write the file, if necessary
+	content := f.Content
+	if write {
+		if content == "" {
+			content = check.Token()
+		}
+
+		w, err := client.Create(f.Path)
+		if err != nil {
+			return check.Classify(err, check.Protocol), fmt.Errorf("could not create file: %w", err)
+		}
+		_, err = w.Write([]byte(content))
+		if err != nil {
+			w.Close()
+			return check.Classify(err, check.Protocol), fmt.Errorf("could not write file: %w", err)
+		}
+		err = w.Close()
+		if err != nil {
+			return check.Classify(err, check.Protocol), fmt.Errorf("could not write file: %w", err)
+		}
+		if !keep {
+			defer client.Remove(f.Path) //nolint:errcheck
+		}
+	}
+
+	// Read the file
+	r, err := client.Open(f.Path)
+	if err != nil {
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not open file: %w", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxFile))
+	if err != nil {
+		return check.Classify(err, check.Protocol), fmt.Errorf("could not read file: %w", err)
+	}
+
+	// Check the contents
+	if write && string(data) != content {
+		return check.ContentMismatch, fmt.Errorf("contents read back did not match what was written")
+	}
+	if failure, err := match(f.ContentRegex, data); err != nil {
+		return failure, fmt.Errorf("contents %w", err)
+	}
+	if f.Hash != "" {
+		digest := sha256.Sum256(data)
+		if hash := hex.EncodeToString(digest[:]); hash != strings.ToLower(f.Hash) {
+			return check.ContentMismatch, fmt.Errorf("incorrect hash: got %s", hash)
+		}
+	}
+
+	return check.None, nil
+}
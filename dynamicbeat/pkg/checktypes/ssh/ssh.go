@@ -3,26 +3,44 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/pkg/sftp"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "ssh",
+		Description: "Log in over SSH and run a command",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the SSH check
 // it implements the "check" interface
 type Definition struct {
 	Config       check.Config // generic metadata about the check
 	Host         string       `optiontype:"required"`                    // IP or hostname of the host to run the SSH check against
 	Username     string       `optiontype:"required"`                    // The user to login with over ssh
-	Password     string       `optiontype:"required"`                    // The password for the user that you wish to login with
-	Cmd          string       `optiontype:"required"`                    // The command to execute once ssh connection established
+	Password     string       `optiontype:"optional"`                    // The password for the user that you wish to login with
+	PrivateKey   string       `optiontype:"optional"`                    // PEM-encoded private key to login with
+	KeyFile      string       `optiontype:"optional"`                    // Private key file in the files_dir directory to login with
+	Passphrase   string       `optiontype:"optional"`                    // The passphrase for an encrypted private key
+	HostKey      string       `optiontype:"optional"`                    // The expected SHA256 fingerprint of the server's host key
+	Cmd          string       `optiontype:"optional"`                    // The command to execute once ssh connection established
 	MatchContent string       `optiontype:"optional"`                    // Whether or not to match content like checking files
 	ContentRegex string       `optiontype:"optional" optiondefault:".*"` // Regex to match if reading a file
 	Port         string       `optiontype:"optional" optiondefault:"22"` // The port to attempt an ssh connection on
+	Commands     []*Command   `optiontype:"list"`                        // Commands to run, with the results they are expected to have
+	Files        []*File      `optiontype:"list"`                        // Files to read or write over SFTP
 }
 
 // Run a single instance of the check
@@ -31,20 +49,16 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
 
 	// Config SSH client
-	// TODO: change timeout to be relative to the parent context's timeout
-	config := &ssh.ClientConfig{
-		User: d.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(d.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         20 * time.Second,
+	auth, err := d.auth()
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error configuring authentication: %s", err)
+		return result
 	}
 
-	// Create the ssh client
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", d.Host, d.Port), config)
-	if err != nil {
-		result.Message = fmt.Sprintf("Error creating ssh client: %s", err)
+	// Connect and log in
+	client := Login(ctx, net.JoinHostPort(d.Host, d.Port), d.Username, auth, d.HostKey, &result)
+	if client == nil {
 		return result
 	}
 	defer func() {
@@ -54,45 +68,68 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		}
 	}()
 
-	// Create a session from the connection
-	session, err := client.NewSession()
-	if err != nil {
-		result.Message = fmt.Sprintf("Error creating a ssh session: %s", err)
-		return result
-	}
-	defer func() {
-		err = session.Close()
-		if err != nil && err.Error() != "EOF" {
-			zap.S().Warnf("Failed to close SSH session connection: %s", err)
+	// Run the single command from older checks
+	if d.Cmd != "" {
+		output, err := combinedOutput(client, d.Cmd)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Error executing command: %s", err)
+			return result
 		}
-	}()
 
-	// Run a command
-	output, err := session.CombinedOutput(d.Cmd)
-	if err != nil {
-		result.Message = fmt.Sprintf("Error executing command: %s", err)
-		return result
-	}
+		// Check if we are going to match content
+		if matchContent, _ := strconv.ParseBool(d.MatchContent); matchContent {
+			regex, err := regexp.Compile(d.ContentRegex)
+			if err != nil {
+				result.Failure = check.DefinitionError
+				result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
+				return result
+			}
 
-	// Check if we are going to match content
-	if matchContent, _ := strconv.ParseBool(d.MatchContent); !matchContent {
-		// If we made it here the check passes
-		result.Message = fmt.Sprintf("Command %s executed successfully: %s", d.Cmd, output)
-		result.Passed = true
-		return result
+			// Check if the content matches
+			if !regex.Match(output) {
+				result.Failure = check.ContentMismatch
+				result.Message = "Matching content not found"
+				return result
+			}
+		} else {
+			result.Message = fmt.Sprintf("Command %s executed successfully: %s", d.Cmd, output)
+		}
 	}
 
-	// Match some content
-	regex, err := regexp.Compile(d.ContentRegex)
-	if err != nil {
-		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
-		return result
+	// Run each command
+	for i, c := range d.Commands {
+		code, failure, err := c.run(client)
+		if code >= 0 {
+			result.Details[fmt.Sprintf("exit_code_%d", i+1)] = strconv.Itoa(code)
+		}
+		if err != nil {
+			result.Failure = failure
+			result.Message = fmt.Sprintf("Command %d (%s) failed : %s", i+1, c.Cmd, err)
+			return result
+		}
 	}
 
-	// Check if the content matches
-	if !regex.Match(output) {
-		result.Message = "Matching content not found"
-		return result
+	// Check each file over SFTP
+	if len(d.Files) > 0 {
+		start := time.Now()
+		sftpClient, err := sftp.NewClient(client)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Error starting SFTP session: %s", err)
+			return result
+		}
+		defer sftpClient.Close()
+
+		for _, f := range d.Files {
+			failure, err := f.check(sftpClient)
+			if err != nil {
+				result.Failure = failure
+				result.Message = fmt.Sprintf("File %s failed : %s", f.Path, err)
+				return result
+			}
+		}
+		result.Time("sftp", start)
 	}
 
 	// If we reach here the check is successful
@@ -100,6 +137,120 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	return result
 }
 
+// Login connects to an SSH server and authenticates as the user. If hostKey
+// is set, the server's host key must have that SHA256 fingerprint. The
+// connect and auth phases, the host key, and the server's banner are recorded
+// in the result. If logging in fails, the result's failure and message are
+// set, and nil is returned.
+func Login(ctx context.Context, addr string, user string, auth []ssh.AuthMethod, hostKey string, result *check.Result) *ssh.Client {
+	if result.Details == nil {
+		result.Details = make(map[string]string)
+	}
+	var hostKeyErr error
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: auth,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			result.Details["host_key"] = fingerprint
+			if hostKey != "" && fingerprint != "SHA256:"+strings.TrimPrefix(hostKey, "SHA256:") {
+				hostKeyErr = fmt.Errorf("host key %s does not match the expected fingerprint", fingerprint)
+				return hostKeyErr
+			}
+			return nil
+		},
+		Timeout: check.Remaining(ctx),
+	}
+
+	// Connect to the server. The connection's deadline makes sure everything
+	// done over the connection obeys the check's deadline.
+	start := time.Now()
+	conn, err := check.Dial(ctx, "tcp", addr)
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Error creating ssh client: %s", err)
+		return nil
+	}
+	result.Time("connect", start)
+
+	// Create the ssh client. The auth timing includes the handshake, since
+	// the two can't be measured separately.
+	start = time.Now()
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		switch {
+		case hostKeyErr != nil:
+			result.Failure = check.ContentMismatch
+		case strings.Contains(err.Error(), "unable to authenticate"):
+			result.Failure = check.Auth
+		default:
+			result.Failure = check.Classify(err, check.Protocol)
+		}
+		result.Message = fmt.Sprintf("Error creating ssh client: %s", err)
+		return nil
+	}
+	result.Time("auth", start)
+	result.Details["banner"] = string(c.ServerVersion())
+
+	return ssh.NewClient(c, chans, reqs)
+}
+
+// PasswordAuth returns the authentication methods for logging in with a
+// password: the password method, and keyboard-interactive for servers that
+// only allow that.
+func PasswordAuth(password string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(password),
+		ssh.KeyboardInteractive(func(_ string, _ string, questions []string, _ []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}),
+	}
+}
+
+// auth returns the authentication methods to try, based on the credentials
+// given in the definition. Passwords are tried with both the password and
+// keyboard-interactive methods.
+func (d *Definition) auth() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	key := []byte(d.PrivateKey)
+	if d.KeyFile != "" {
+		var err error
+		key, err = files.Read(d.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(key) > 0 {
+		var signer ssh.Signer
+		var err error
+		if d.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(d.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %s", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if d.Password != "" {
+		methods = append(methods, PasswordAuth(d.Password)...)
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("one of Password, PrivateKey, or KeyFile must be set")
+	}
+
+	return methods, nil
+}
+
 // GetConfig returns the current CheckConfig struct this check has been
 // configured with.
 func (d *Definition) GetConfig() check.Config {
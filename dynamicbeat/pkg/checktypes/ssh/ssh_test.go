@@ -0,0 +1,329 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
+	"golang.org/x/crypto/ssh"
+)
+
+// A server is a stand-in SSH server that accepts a single user, runs a few
+// canned commands, and keeps files for SFTP in memory.
+type server struct {
+	port    string
+	hostKey ssh.PublicKey
+	key     string // PEM-encoded private key that the user can log in with
+}
+
+// run returns the stdout, stderr, and exit code of a canned command.
+func run(cmd string) (string, string, uint32) {
+	switch cmd {
+	case "whoami":
+		return "admin\n", "", 0
+	case "systemctl is-active nginx":
+		return "active\n", "", 0
+	case "warn":
+		return "", "disk is almost full\n", 0
+	case "false":
+		return "", "", 1
+	default:
+		return "", "sh: " + cmd + ": not found\n", 127
+	}
+}
+
+func serve(t *testing.T) *server {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDER, err := x509.MarshalECPrivateKey(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPub, err := ssh.NewPublicKey(&userKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(password) == "changeme" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "admin" && bytes.Equal(key.Marshal(), userPub.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+	handlers := sftp.InMemHandler()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn, config, handlers)
+		}
+	}()
+
+	return &server{
+		port:    strconv.Itoa(l.Addr().(*net.TCPAddr).Port),
+		hostKey: hostSigner.PublicKey(),
+		key:     string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: userDER})),
+	}
+}
+
+func handle(conn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go session(channel, requests, handlers)
+	}
+}
+
+func session(channel ssh.Channel, requests <-chan *ssh.Request, handlers sftp.Handlers) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type == "subsystem" && string(req.Payload[4:]) == "sftp" {
+			_ = req.Reply(true, nil)
+			_ = sftp.NewRequestServer(channel, handlers).Serve()
+			return
+		}
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+
+		stdout, stderr, code := run(string(req.Payload[4:]))
+		_, _ = channel.Write([]byte(stdout))
+		_, _ = channel.Stderr().Write([]byte(stderr))
+		status := make([]byte, 4)
+		binary.BigEndian.PutUint32(status, code)
+		_, _ = channel.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+func TestAuth(t *testing.T) {
+	s := serve(t)
+	block, _ := pem.Decode([]byte(s.key))
+	//nolint:staticcheck // encrypted PEM is what older key files use
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("hunter2"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey := string(pem.EncodeToMemory(encrypted))
+
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, "id_ecdsa"), []byte(s.key), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.Configure(dir)
+	defer files.Configure("")
+
+	fingerprint := ssh.FingerprintSHA256(s.hostKey)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"Password", Definition{Password: "changeme"}, true, check.None},
+		{"WrongPassword", Definition{Password: "password"}, false, check.Auth},
+		{"PrivateKey", Definition{PrivateKey: s.key}, true, check.None},
+		{"KeyFile", Definition{KeyFile: "id_ecdsa"}, true, check.None},
+		{"MissingKeyFile", Definition{KeyFile: "id_rsa"}, false, check.DefinitionError},
+		{"Passphrase", Definition{PrivateKey: encryptedKey, Passphrase: "hunter2"}, true, check.None},
+		{"WrongPassphrase", Definition{PrivateKey: encryptedKey, Passphrase: "hunter3"}, false, check.DefinitionError},
+		{"InvalidKey", Definition{PrivateKey: "not a key"}, false, check.DefinitionError},
+		{"NoCredentials", Definition{}, false, check.DefinitionError},
+		{"HostKey", Definition{Password: "changeme", HostKey: fingerprint}, true, check.None},
+		{"HostKeyWithoutPrefix", Definition{Password: "changeme", HostKey: fingerprint[len("SHA256:"):]}, true, check.None},
+		{"WrongHostKey", Definition{Password: "changeme", HostKey: "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"}, false, check.ContentMismatch},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host, d.Port, d.Username = "127.0.0.1", s.port, "admin"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if c.failure != check.DefinitionError && r.Details["host_key"] != fingerprint {
+				t.Errorf("host_key = %s, want %s", r.Details["host_key"], fingerprint)
+			}
+		})
+	}
+}
+
+func TestCmd(t *testing.T) {
+	s := serve(t)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"Command", Definition{Cmd: "whoami"}, true, check.None},
+		{"Match", Definition{Cmd: "whoami", MatchContent: "true", ContentRegex: "^admin"}, true, check.None},
+		{"Mismatch", Definition{Cmd: "whoami", MatchContent: "true", ContentRegex: "^root"}, false, check.ContentMismatch},
+		{"Regex", Definition{Cmd: "whoami", MatchContent: "true", ContentRegex: "("}, false, check.DefinitionError},
+		{"ExitCode", Definition{Cmd: "false"}, false, check.Protocol},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host, d.Port, d.Username, d.Password = "127.0.0.1", s.port, "admin", "changeme"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	s := serve(t)
+
+	cases := []struct {
+		name     string
+		commands []*Command
+		passed   bool
+		failure  check.Failure
+		codes    map[string]string
+	}{
+		{"Commands", []*Command{
+			{Cmd: "whoami", StdoutRegex: `^admin\n$`},
+			{Cmd: "systemctl is-active nginx", StdoutRegex: "^active"},
+			{Cmd: "false", ExitCode: 1},
+		}, true, check.None, map[string]string{"exit_code_1": "0", "exit_code_2": "0", "exit_code_3": "1"}},
+		{"Stderr", []*Command{{Cmd: "warn", StderrRegex: "almost full"}}, true, check.None, nil},
+		{"WrongStdout", []*Command{{Cmd: "whoami", StdoutRegex: "^root$"}}, false, check.ContentMismatch, nil},
+		{"WrongExitCode", []*Command{
+			{Cmd: "whoami"},
+			{Cmd: "nginx -t"},
+			{Cmd: "whoami"},
+		}, false, check.ContentMismatch, map[string]string{"exit_code_1": "0", "exit_code_2": "127"}},
+		{"Regex", []*Command{{Cmd: "whoami", StdoutRegex: "("}}, false, check.DefinitionError, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Definition{Host: "127.0.0.1", Port: s.port, Username: "admin", Password: "changeme", Commands: c.commands}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			for k, v := range c.codes {
+				if r.Details[k] != v {
+					t.Errorf("Details[%s] = %q, want %q", k, r.Details[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestFiles(t *testing.T) {
+	s := serve(t)
+	digest := sha256.Sum256([]byte("Authorized users only\n"))
+	hash := hex.EncodeToString(digest[:])
+
+	cases := []struct {
+		name    string
+		files   []*File
+		passed  bool
+		failure check.Failure
+	}{
+		{"WriteAndKeep", []*File{
+			{Path: "/motd", Write: "true", Content: "Authorized users only\n", Keep: "true"},
+			{Path: "/motd", ContentRegex: "^Authorized", Hash: hash},
+		}, true, check.None},
+		{"WriteToken", []*File{{Path: "/token", Write: "true"}}, true, check.None},
+		{"Removed", []*File{{Path: "/token"}}, false, check.Protocol},
+		{"WrongContent", []*File{{Path: "/motd", ContentRegex: "^Welcome"}}, false, check.ContentMismatch},
+		{"WrongHash", []*File{{Path: "/motd", Hash: strings.Repeat("0", 64)}}, false, check.ContentMismatch},
+		{"Missing", []*File{{Path: "/etc/shadow"}}, false, check.Protocol},
+	}
+
+	// The cases run in order, since later ones read the files written by
+	// earlier ones
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Definition{Host: "127.0.0.1", Port: s.port, Username: "admin", Password: "changeme", Files: c.files}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "tcp",
+		Description: "Connect to a TCP port and optionally send and expect data",
+		New:         func() check.Check { return &Definition{network: "tcp"} },
+	})
+	check.Register(check.Type{
+		Name:        "udp",
+		Description: "Send UDP datagrams and expect responses",
+		New:         func() check.Check { return &Definition{network: "udp"} },
+	})
+}
+
+// maxRead is the most data that will be buffered while waiting for a
+// response to match.
+const maxRead = 64 * 1024
+
+// The Definition configures the behavior of the TCP and UDP checks
+// it implements the "check" interface
+type Definition struct {
+	Config check.Config // generic metadata about the check
+	Host   string       `optiontype:"required"` // IP or hostname of the server
+	Port   string       `optiontype:"required"` // Port the server is listening on
+	TLS    string       `optiontype:"optional"` // Whether to use TLS, for TCP only
+	Verify string       `optiontype:"optional"` // Whether TLS certificates should be validated
+	Banner string       `optiontype:"optional"` // Regex the data sent by the server on connection must match, for TCP only
+	Steps  []*Step      `optiontype:"list"`     // Payloads to send and responses to expect, in order
+
+	network string // either tcp or udp, depending on the registered check type
+}
+
+// A Step sends a payload to the server and waits for a response.
+type Step struct {
+	Send     string `optiontype:"optional"`                      // Payload to send to the server
+	Encoding string `optiontype:"optional" optiondefault:"text"` // Encoding of the payload: text, hex, or base64
+	Expect   string `optiontype:"optional"`                      // Regex the response must match
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+	result.Details = make(map[string]string)
+
+	useTLS, _ := strconv.ParseBool(d.TLS)
+	verify, _ := strconv.ParseBool(d.Verify)
+	if d.network == "udp" && (useTLS || d.Banner != "") {
+		result.Failure = check.DefinitionError
+		result.Message = "TLS and Banner are only supported for TCP checks"
+		return result
+	}
+
+	// UDP is connectionless, so a UDP check can only tell that the service is
+	// up if it gets a response
+	if d.network == "udp" {
+		expects := false
+		for _, step := range d.Steps {
+			expects = expects || step.Expect != ""
+		}
+		if !expects {
+			result.Failure = check.DefinitionError
+			result.Message = "UDP checks need at least one step with an Expect regex"
+			return result
+		}
+	}
+
+	// Decode the payloads and compile the regexes before connecting
+	payloads := make([][]byte, len(d.Steps))
+	regexes := make([]*regexp.Regexp, len(d.Steps))
+	for i, step := range d.Steps {
+		payload, err := decode(step.Send, step.Encoding)
+		if err != nil {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Error decoding payload for step %d : %s", i+1, err)
+			return result
+		}
+		payloads[i] = payload
+
+		if step.Expect != "" {
+			regexes[i], err = regexp.Compile(step.Expect)
+			if err != nil {
+				result.Failure = check.DefinitionError
+				result.Message = fmt.Sprintf("Error compiling regex string %s : %s", step.Expect, err)
+				return result
+			}
+		}
+	}
+	banner, err := regexp.Compile(d.Banner)
+	if err != nil {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.Banner, err)
+		return result
+	}
+
+	// Connect to the server. The connection's deadline makes sure all reads
+	// and writes obey the check's deadline.
+	start := time.Now()
+	conn, err := check.Dial(ctx, d.network, net.JoinHostPort(d.Host, d.Port))
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Connection to %s failed : %s", d.Host, err)
+		return result
+	}
+	result.Time("connect", start)
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			zap.S().Warnf("Failed to close %s connection: %s", d.network, err)
+		}
+	}()
+
+	if useTLS {
+		start = time.Now()
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.Host, InsecureSkipVerify: !verify})
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			result.Failure = check.Classify(err, check.TLS)
+			result.Message = fmt.Sprintf("TLS handshake with %s failed : %s", d.Host, err)
+			return result
+		}
+		result.Time("tls", start)
+		conn = tlsConn
+	}
+
+	r := &reader{conn: conn, datagrams: d.network == "udp"}
+
+	// Read the banner, if necessary
+	if d.Banner != "" {
+		match, err := r.expect(banner)
+		if err != nil {
+			result.Failure = check.Classify(err, check.ContentMismatch)
+			result.Message = fmt.Sprintf("Did not receive expected banner : %s", err)
+			return result
+		}
+		result.Details["banner"] = match
+	}
+
+	// Send each payload and check each response
+	for i := range d.Steps {
+		if len(payloads[i]) > 0 {
+			_, err = conn.Write(payloads[i])
+			if err != nil {
+				result.Failure = check.Classify(err, check.Protocol)
+				result.Message = fmt.Sprintf("Sending payload for step %d failed : %s", i+1, err)
+				return result
+			}
+		}
+
+		if regexes[i] == nil {
+			continue
+		}
+		match, err := r.expect(regexes[i])
+		if err != nil {
+			result.Failure = check.Classify(err, check.ContentMismatch)
+			result.Message = fmt.Sprintf("Did not receive expected response for step %d : %s", i+1, err)
+			return result
+		}
+		result.Details[fmt.Sprintf("matched_content_%d", i+1)] = match
+	}
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// decode converts a payload from its encoding into bytes.
+func decode(payload string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "text":
+		return []byte(payload), nil
+	case "hex":
+		return hex.DecodeString(payload)
+	case "base64":
+		return base64.StdEncoding.DecodeString(payload)
+	default:
+		return nil, fmt.Errorf("unknown encoding '%s' - must be one of text, hex, or base64", encoding)
+	}
+}
+
+// A reader collects responses from the server until they match a regex.
+type reader struct {
+	conn      net.Conn
+	datagrams bool   // if true, each read is matched on its own instead of being added to the buffer
+	buf       []byte // data that has been read but not yet matched
+}
+
+// expect reads from the connection until the data matches the regex. It
+// returns the last capture group of the match, or the whole match if the regex
+// has no capture groups, and discards everything up to the end of the match.
+func (r *reader) expect(regex *regexp.Regexp) (string, error) {
+	for {
+		if loc := regex.FindSubmatchIndex(r.buf); loc != nil {
+			// Use the whole match if the last group didn't participate
+			last := len(loc) - 2
+			if loc[last] < 0 {
+				last = 0
+			}
+			match := string(r.buf[loc[last]:loc[last+1]])
+			r.buf = r.buf[loc[1]:]
+			return match, nil
+		}
+		if len(r.buf) >= maxRead {
+			return "", fmt.Errorf("no match in the first %d bytes", maxRead)
+		}
+
+		chunk := make([]byte, maxRead)
+		n, err := r.conn.Read(chunk)
+		if n == 0 && err != nil {
+			return "", err
+		}
+		if r.datagrams {
+			r.buf = chunk[:n]
+		} else {
+			r.buf = append(r.buf, chunk[:n]...)
+		}
+	}
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
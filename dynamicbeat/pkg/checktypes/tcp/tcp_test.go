@@ -0,0 +1,168 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name     string
+		payload  string
+		encoding string
+		want     []byte
+		err      bool
+	}{
+		{"Text", "PING\r\n", "text", []byte("PING\r\n"), false},
+		{"Hex", "deadBEEF", "hex", []byte{0xde, 0xad, 0xbe, 0xef}, false},
+		{"Base64", "AAEC", "base64", []byte{0, 1, 2}, false},
+		{"InvalidHex", "xyz", "hex", nil, true},
+		{"UnknownEncoding", "PING", "rot13", nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := decode(c.payload, c.encoding)
+			if (err != nil) != c.err {
+				t.Fatalf("decode() error = %v, want error: %t", err, c.err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("decode() = %x, want %x", got, c.want)
+			}
+		})
+	}
+}
+
+// serveTCP starts a server that sends a banner, then answers each line it
+// receives with PONG, and returns its port.
+func serveTCP(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_8.9\r\n"))
+				lines := bufio.NewScanner(conn)
+				for lines.Scan() {
+					_, _ = conn.Write([]byte("PONG " + lines.Text() + "\r\n"))
+				}
+			}()
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+// serveUDP starts a server that echoes each datagram back in upper case, and
+// returns its port.
+func serveUDP(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(bytes.ToUpper(buf[:n]), addr)
+		}
+	}()
+
+	return strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func TestRun(t *testing.T) {
+	tcpPort, udpPort := serveTCP(t), serveUDP(t)
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+		details map[string]string
+	}{
+		{
+			"Banner",
+			Definition{network: "tcp", Port: tcpPort, Banner: `SSH-2\.0-(\S+)`},
+			true, check.None, map[string]string{"banner": "OpenSSH_8.9"},
+		},
+		{
+			"Steps",
+			Definition{network: "tcp", Port: tcpPort, Steps: []*Step{
+				{Send: "one\n", Encoding: "text", Expect: `PONG (\w+)`},
+				{Send: "74776f0a", Encoding: "hex", Expect: `PONG (\w+)`},
+			}},
+			true, check.None, map[string]string{"matched_content_1": "one", "matched_content_2": "two"},
+		},
+		{
+			"Mismatch",
+			Definition{network: "tcp", Port: tcpPort, Banner: "^220 "},
+			false, check.Timeout, nil,
+		},
+		{
+			"UDP",
+			Definition{network: "udp", Port: udpPort, Steps: []*Step{{Send: "status", Encoding: "text", Expect: "^STATUS$"}}},
+			true, check.None, nil,
+		},
+		{
+			"UDPBanner",
+			Definition{network: "udp", Port: udpPort, Banner: "hello"},
+			false, check.DefinitionError, nil,
+		},
+		{
+			"UDPNoExpect",
+			Definition{network: "udp", Port: udpPort, Steps: []*Step{{Send: "status", Encoding: "text"}}},
+			false, check.DefinitionError, nil,
+		},
+		{
+			"Encoding",
+			Definition{network: "tcp", Port: tcpPort, Steps: []*Step{{Send: "zz", Encoding: "hex"}}},
+			false, check.DefinitionError, nil,
+		},
+		{
+			"Regex",
+			Definition{network: "tcp", Port: tcpPort, Steps: []*Step{{Encoding: "text", Expect: "("}}},
+			false, check.DefinitionError, nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "127.0.0.1"
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			for k, v := range c.details {
+				if r.Details[k] != v {
+					t.Errorf("Details[%s] = %q, want %q", k, r.Details[k], v)
+				}
+			}
+		})
+	}
+}
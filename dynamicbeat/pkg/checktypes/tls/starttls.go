@@ -0,0 +1,162 @@
+package tls
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// startTLSProtocols are the protocols that starttls can upgrade to TLS.
+var startTLSProtocols = []string{"smtp", "imap", "ftp", "ldap", "xmpp"}
+
+// ldapStartTLS is an LDAP extended request for the StartTLS operation, with a
+// message ID of 1.
+var ldapStartTLS = []byte{
+	0x30, 0x1d, // LDAPMessage
+	0x02, 0x01, 0x01, // messageID
+	0x77, 0x18, // extendedReq
+	0x80, 0x16, // requestName
+	'1', '.', '3', '.', '6', '.', '1', '.', '4', '.', '1', '.', '1', '4', '6', '6', '.', '2', '0', '0', '3', '7',
+}
+
+// starttls asks the server to upgrade the connection to TLS using the named
+// protocol's STARTTLS command. When it returns successfully, the next data on
+// the connection is the TLS handshake.
+func starttls(conn net.Conn, protocol string, host string) error {
+	// The reader is only used until the server agrees to start TLS, at which
+	// point the server waits for the client to begin the handshake, so no
+	// buffered data is lost
+	r := bufio.NewReader(conn)
+
+	switch protocol {
+	case "smtp":
+		if err := readReply(r, "220"); err != nil {
+			return fmt.Errorf("bad greeting: %w", err)
+		}
+		if err := send(conn, "EHLO scorestack\r\n"); err != nil {
+			return err
+		}
+		if err := readReply(r, "250"); err != nil {
+			return fmt.Errorf("bad EHLO reply: %w", err)
+		}
+		if err := send(conn, "STARTTLS\r\n"); err != nil {
+			return err
+		}
+		return readReply(r, "220")
+	case "ftp":
+		if err := readReply(r, "220"); err != nil {
+			return fmt.Errorf("bad greeting: %w", err)
+		}
+		if err := send(conn, "AUTH TLS\r\n"); err != nil {
+			return err
+		}
+		return readReply(r, "234")
+	case "imap":
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "* OK") {
+			return fmt.Errorf("bad greeting: %s", strings.TrimSpace(line))
+		}
+		if err := send(conn, "a1 STARTTLS\r\n"); err != nil {
+			return err
+		}
+		for {
+			line, err = r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				break
+			}
+		}
+		if !strings.HasPrefix(line, "a1 OK") {
+			return fmt.Errorf("STARTTLS refused: %s", strings.TrimSpace(line))
+		}
+		return nil
+	case "ldap":
+		if _, err := conn.Write(ldapStartTLS); err != nil {
+			return err
+		}
+		packet, err := ber.ReadPacket(r)
+		if err != nil {
+			return err
+		}
+		if len(packet.Children) < 2 || len(packet.Children[1].Children) < 1 {
+			return fmt.Errorf("malformed StartTLS response")
+		}
+		code, ok := packet.Children[1].Children[0].Value.(int64)
+		if !ok || code != 0 {
+			return fmt.Errorf("StartTLS refused with result code %v", packet.Children[1].Children[0].Value)
+		}
+		return nil
+	case "xmpp":
+		header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", host)
+		if err := send(conn, header); err != nil {
+			return err
+		}
+		features, err := readUntil(r, "</stream:features>")
+		if err != nil {
+			return fmt.Errorf("did not receive stream features: %w", err)
+		}
+		if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+			return fmt.Errorf("server does not offer STARTTLS")
+		}
+		if err := send(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+			return err
+		}
+		reply, err := readUntil(r, ">")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(reply, "<proceed") {
+			return fmt.Errorf("STARTTLS refused: %s", reply)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported STARTTLS protocol '%s' - must be one of smtp, imap, ftp, ldap, or xmpp", protocol)
+	}
+}
+
+func send(conn net.Conn, command string) error {
+	_, err := conn.Write([]byte(command))
+	return err
+}
+
+// readReply reads a possibly multi-line SMTP or FTP reply, and makes sure it
+// has the expected status code.
+func readReply(r *bufio.Reader, code string) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		// The last line of a reply has a space after the code
+		if len(line) < 4 || line[3] != '-' {
+			if !strings.HasPrefix(line, code) {
+				return fmt.Errorf("expected %s, got %s", code, strings.TrimSpace(line))
+			}
+			return nil
+		}
+	}
+}
+
+// readUntil reads from the server until the data contains the marker.
+func readUntil(r *bufio.Reader, marker string) (string, error) {
+	var buf bytes.Buffer
+	for !strings.HasSuffix(buf.String(), marker) {
+		b, err := r.ReadByte()
+		if err != nil {
+			return buf.String(), err
+		}
+		buf.WriteByte(b)
+	}
+
+	return buf.String(), nil
+}
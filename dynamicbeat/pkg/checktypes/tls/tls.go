@@ -0,0 +1,301 @@
+package tls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"go.uber.org/zap"
+)
+
+func init() {
+	check.Register(check.Type{
+		Name:        "tls",
+		Description: "Validate a server's TLS certificate and configuration",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
+// versions maps the names of TLS versions to their values.
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// The Definition configures the behavior of the TLS check
+// it implements the "check" interface
+type Definition struct {
+	Config         check.Config // generic metadata about the check
+	Host           string       `optiontype:"required"`                      // IP or hostname of the server
+	Port           string       `optiontype:"required"`                      // Port the server is listening on
+	ServerName     string       `optiontype:"optional"`                      // Name to send with SNI and to validate the certificate against, if different from Host
+	StartTLS       string       `optiontype:"optional"`                      // Protocol to use to upgrade to TLS: smtp, imap, ftp, ldap, or xmpp
+	CABundle       string       `optiontype:"optional"`                      // PEM-encoded CA certificates to validate the chain against, instead of the system roots
+	VerifyChain    string       `optiontype:"optional" optiondefault:"true"` // Whether the certificate chain must be valid
+	VerifyHostname string       `optiontype:"optional" optiondefault:"true"` // Whether the certificate must be valid for the server name
+	MinDaysValid   int          `optiontype:"optional"`                      // Minimum number of days before the certificate expires
+	MinVersion     string       `optiontype:"optional"`                      // Minimum TLS version the server must negotiate: 1.0, 1.1, 1.2, or 1.3
+	CipherSuites   []string     `optiontype:"optional"`                      // Names of the cipher suites the server may negotiate
+	Fingerprint    string       `optiontype:"optional"`                      // Expected SHA-256 fingerprint of the server's certificate, in hex
+}
+
+// Run a single instance of the check
+func (d *Definition) Run(ctx context.Context) check.Result {
+	// Initialize empty result
+	result := check.Result{Timestamp: time.Now(), Metadata: d.Config.Metadata}
+
+	serverName := d.ServerName
+	if serverName == "" {
+		serverName = d.Host
+	}
+	verifyChain, _ := strconv.ParseBool(d.VerifyChain)
+	verifyHostname, _ := strconv.ParseBool(d.VerifyHostname)
+
+	// Validate the definition before connecting
+	var minVersion uint16
+	if d.MinVersion != "" {
+		var ok bool
+		minVersion, ok = versions[d.MinVersion]
+		if !ok {
+			result.Failure = check.DefinitionError
+			result.Message = fmt.Sprintf("Invalid MinVersion '%s' : must be one of 1.0, 1.1, 1.2, or 1.3", d.MinVersion)
+			return result
+		}
+	}
+	startTLS := strings.ToLower(d.StartTLS)
+	if startTLS != "" && !contains(startTLSProtocols, startTLS) {
+		result.Failure = check.DefinitionError
+		result.Message = fmt.Sprintf("Invalid StartTLS '%s' : must be one of smtp, imap, ftp, ldap, or xmpp", d.StartTLS)
+		return result
+	}
+	var roots *x509.CertPool
+	if d.CABundle != "" {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(d.CABundle)) {
+			result.Failure = check.DefinitionError
+			result.Message = "CABundle does not contain any PEM-encoded certificates"
+			return result
+		}
+	}
+
+	// Connect to the server. The connection's deadline makes sure STARTTLS
+	// and the handshake obey the check's deadline.
+	start := time.Now()
+	conn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
+	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
+		result.Message = fmt.Sprintf("Connection to %s failed : %s", d.Host, err)
+		return result
+	}
+	result.Time("connect", start)
+	defer func() {
+		err = conn.Close()
+		if err != nil {
+			zap.S().Warnf("Failed to close TLS connection: %s", err)
+		}
+	}()
+
+	if startTLS != "" {
+		start = time.Now()
+		err = starttls(conn, startTLS, serverName)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("STARTTLS failed : %s", err)
+			return result
+		}
+		result.Time("starttls", start)
+	}
+
+	// The certificate is validated after the handshake, so that each problem
+	// can be reported separately. Old protocol versions are allowed so that
+	// they can be reported instead of failing the handshake.
+	start = time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, //nolint:gosec
+		MinVersion:         tls.VersionTLS10,
+	})
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		result.Failure = check.Classify(err, check.TLS)
+		result.Message = fmt.Sprintf("TLS handshake with %s failed : %s", d.Host, err)
+		return result
+	}
+	result.Time("tls", start)
+
+	state := tlsConn.ConnectionState()
+	cert := state.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	result.Details = map[string]string{
+		"subject":      cert.Subject.String(),
+		"issuer":       cert.Issuer.String(),
+		"not_before":   cert.NotBefore.Format(time.RFC3339),
+		"not_after":    cert.NotAfter.Format(time.RFC3339),
+		"sans":         strings.Join(sans(cert), ","),
+		"version":      versionName(state.Version),
+		"cipher_suite": tls.CipherSuiteName(state.CipherSuite),
+		"fingerprint":  fingerprint,
+	}
+
+	// Validate the certificate
+	if verifyChain {
+		intermediates := x509.NewCertPool()
+		for _, c := range state.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err = cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		if err != nil {
+			result.Failure = check.TLS
+			result.Message = fmt.Sprintf("Certificate chain is invalid : %s", err)
+			return result
+		}
+	}
+	if verifyHostname {
+		err = cert.VerifyHostname(serverName)
+		if err != nil {
+			result.Failure = check.TLS
+			result.Message = fmt.Sprintf("Certificate is not valid for %s : %s", serverName, err)
+			return result
+		}
+	}
+	if d.MinDaysValid > 0 {
+		remaining := time.Until(cert.NotAfter)
+		if remaining < time.Duration(d.MinDaysValid)*24*time.Hour {
+			result.Failure = check.TLS
+			result.Message = fmt.Sprintf("Certificate expires in %.1f days, less than the required %d", remaining.Hours()/24, d.MinDaysValid)
+			return result
+		}
+	}
+	if d.Fingerprint != "" {
+		expected := strings.ToLower(strings.ReplaceAll(d.Fingerprint, ":", ""))
+		if expected != fingerprint {
+			result.Failure = check.TLS
+			result.Message = fmt.Sprintf("Certificate fingerprint %s does not match the expected fingerprint", fingerprint)
+			return result
+		}
+	}
+
+	// Validate the connection parameters
+	if state.Version < minVersion {
+		result.Failure = check.TLS
+		result.Message = fmt.Sprintf("Server negotiated %s, older than the minimum of TLS %s", versionName(state.Version), d.MinVersion)
+		return result
+	}
+	if len(d.CipherSuites) > 0 && !contains(d.CipherSuites, tls.CipherSuiteName(state.CipherSuite)) {
+		result.Failure = check.TLS
+		result.Message = fmt.Sprintf("Server negotiated cipher suite %s, which is not allowed", tls.CipherSuiteName(state.CipherSuite))
+		return result
+	}
+
+	// The server negotiates the newest version both sides support, so make
+	// sure it also refuses clients that only support older versions
+	if minVersion > tls.VersionTLS10 {
+		version, err := d.accepts(ctx, startTLS, serverName, minVersion-1)
+		if err != nil {
+			result.Failure = check.Classify(err, check.Protocol)
+			result.Message = fmt.Sprintf("Checking for versions older than TLS %s failed : %s", d.MinVersion, err)
+			return result
+		}
+		if version != 0 {
+			result.Failure = check.TLS
+			result.Message = fmt.Sprintf("Server accepted %s, older than the minimum of TLS %s", versionName(version), d.MinVersion)
+			return result
+		}
+	}
+
+	// If we reach here the check passes
+	result.Passed = true
+	return result
+}
+
+// accepts opens a second connection to the server and offers only the TLS
+// versions up to maxVersion. It returns the version the server negotiated, or
+// 0 if the server refused the handshake.
+func (d *Definition) accepts(ctx context.Context, startTLS string, serverName string, maxVersion uint16) (uint16, error) {
+	conn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if startTLS != "" {
+		err = starttls(conn, startTLS, serverName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, //nolint:gosec
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         maxVersion,
+	})
+	err = tlsConn.HandshakeContext(ctx)
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if err != nil {
+		return 0, nil
+	}
+
+	return tlsConn.ConnectionState().Version, nil
+}
+
+// sans returns all the subject alternative names in a certificate.
+func sans(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+// versionName returns the name of a TLS version, like "TLS 1.2".
+func versionName(version uint16) string {
+	for name, v := range versions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+
+	return fmt.Sprintf("0x%04X", version)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetConfig returns the current CheckConfig struct this check has been
+// configured with.
+func (d *Definition) GetConfig() check.Config {
+	return d.Config
+}
+
+// SetConfig reconfigures this check with a new CheckConfig struct.
+func (d *Definition) SetConfig(c check.Config) {
+	d.Config = c
+}
@@ -0,0 +1,159 @@
+package tls
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestVersionName(t *testing.T) {
+	cases := map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+		0x0300:           "0x0300",
+	}
+
+	for version, want := range cases {
+		if got := versionName(version); got != want {
+			t.Errorf("versionName(%#x) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+// certificate creates a self-signed certificate for localhost that is valid
+// for 30 days, and returns it along with its PEM encoding.
+func certificate(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// serve starts a TLS server that runs through an SMTP STARTTLS exchange first
+// if smtp is true, and returns its port. The server supports TLS 1.2 and any
+// older versions down to minVersion.
+func serve(t *testing.T, cert tls.Certificate, smtp bool, minVersion uint16) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: minVersion, MaxVersion: tls.VersionTLS12}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if smtp {
+					r := bufio.NewReader(conn)
+					_, _ = conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+					_, _ = r.ReadString('\n')
+					_, _ = conn.Write([]byte("250-mail.example.com\r\n250 STARTTLS\r\n"))
+					_, _ = r.ReadString('\n')
+					_, _ = conn.Write([]byte("220 Ready to start TLS\r\n"))
+				}
+				_ = tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRun(t *testing.T) {
+	cert, bundle := certificate(t)
+	port, smtpPort := serve(t, cert, false, tls.VersionTLS12), serve(t, cert, true, tls.VersionTLS12)
+	legacyPort := serve(t, cert, false, tls.VersionTLS10)
+	sum := sha256.Sum256(cert.Certificate[0])
+	fingerprint := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	cases := []struct {
+		name    string
+		def     Definition
+		passed  bool
+		failure check.Failure
+	}{
+		{"Valid", Definition{CABundle: bundle}, true, check.None},
+		{"STARTTLS", Definition{Port: smtpPort, StartTLS: "SMTP", CABundle: bundle}, true, check.None},
+		{"UnknownCA", Definition{}, false, check.TLS},
+		{"NoVerify", Definition{VerifyChain: "false"}, true, check.None},
+		{"Hostname", Definition{ServerName: "www.example.com", CABundle: bundle}, false, check.TLS},
+		{"Expiring", Definition{CABundle: bundle, MinDaysValid: 60}, false, check.TLS},
+		{"Fingerprint", Definition{VerifyChain: "false", Fingerprint: fingerprint}, true, check.None},
+		{"WrongFingerprint", Definition{VerifyChain: "false", Fingerprint: strings.Repeat("00", 32)}, false, check.TLS},
+		{"OldVersion", Definition{CABundle: bundle, MinVersion: "1.3"}, false, check.TLS},
+		{"MinVersion", Definition{CABundle: bundle, MinVersion: "1.2"}, true, check.None},
+		{"STARTTLSMinVersion", Definition{Port: smtpPort, StartTLS: "smtp", CABundle: bundle, MinVersion: "1.2"}, true, check.None},
+		{"AcceptsOldVersion", Definition{Port: legacyPort, CABundle: bundle, MinVersion: "1.2"}, false, check.TLS},
+		{"CipherSuite", Definition{CABundle: bundle, CipherSuites: []string{"TLS_RSA_WITH_AES_128_CBC_SHA"}}, false, check.TLS},
+		{"InvalidVersion", Definition{MinVersion: "1.4"}, false, check.DefinitionError},
+		{"InvalidStartTLS", Definition{Port: "1", StartTLS: "pop3"}, false, check.DefinitionError},
+		{"InvalidBundle", Definition{CABundle: "not a certificate"}, false, check.DefinitionError},
+		{"NotTLS", Definition{Port: smtpPort}, false, check.TLS},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.def
+			d.Host = "localhost"
+			if d.Port == "" {
+				d.Port = port
+			}
+			for _, setting := range []*string{&d.VerifyChain, &d.VerifyHostname} {
+				if *setting == "" {
+					*setting = "true"
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			r := d.Run(ctx)
+			if r.Passed != c.passed || r.Failure != c.failure {
+				t.Fatalf("Run() passed = %t with failure %q, want %t with %q: %s", r.Passed, r.Failure, c.passed, c.failure, r.Message)
+			}
+			if r.Passed && r.Details["version"] != "TLS 1.2" {
+				t.Errorf("version = %s, want TLS 1.2", r.Details["version"])
+			}
+		})
+	}
+}
@@ -11,6 +11,14 @@ import (
 	"go.uber.org/zap"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "vnc",
+		Description: "Log in to a VNC server",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the VNC check
 // it implements the "check" interface
 type Definition struct {
@@ -32,14 +40,11 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		},
 	}
 
-	// Make a dialer
-	dialer := net.Dialer{}
-
-	// Dial the vnc server
-	// conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%s", d.Host, d.Port), 5*time.Second)
-	// TODO: create child context with deadline less than the parent context
-	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%s", d.Host, d.Port))
+	// Dial the vnc server. The connection's deadline makes sure the VNC
+	// handshake obeys the check's deadline.
+	conn, err := check.Dial(ctx, "tcp", net.JoinHostPort(d.Host, d.Port))
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Connection to VNC host %s failed : %s", d.Host, err)
 		return result
 	}
@@ -52,6 +57,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 
 	vncClient, err := vnc.Client(conn, &config)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Login to server %s failed : %s", d.Host, err)
 		return result
 	}
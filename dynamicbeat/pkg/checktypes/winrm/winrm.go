@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"time"
@@ -12,6 +13,14 @@ import (
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "winrm",
+		Description: "Log in over WinRM and run a command",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the WinRM check
 // it implements the "check" interface
 type Definition struct {
@@ -34,21 +43,25 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Convert d.Port to int
 	port, err := strconv.Atoi(d.Port)
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = fmt.Sprintf("Failed to convert d.Port to int : %s", err)
 		return result
 	}
 
-	// Another timeout for the bois
+	// Make sure all connections obey the check's deadline
 	params := *winrm.DefaultParameters
+	params.Dial = func(network, addr string) (net.Conn, error) {
+		return check.Dial(ctx, network, addr)
+	}
 
 	// Convert encrypted to bool
 	encrypted, _ := strconv.ParseBool(d.Encrypted)
 
 	// Login to winrm and create client
-	// endpoint := winrm.NewEndpoint(d.Host, port, d.Encrypted, true, nil, nil, nil, 5*time.Second)
-	endpoint := winrm.NewEndpoint(d.Host, port, encrypted, true, nil, nil, nil, 20*time.Second)
+	endpoint := winrm.NewEndpoint(d.Host, port, encrypted, true, nil, nil, nil, check.Remaining(ctx))
 	client, err := winrm.NewClientWithParameters(endpoint, d.Username, d.Password, &params)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Login to WinRM host %s failed : %s", d.Host, err)
 		return result
 	}
@@ -60,12 +73,14 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 
 	_, err = client.Run(powershellCmd, bufOut, bufErr)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Executing command %s failed : %s", d.Cmd, err)
 		return result
 	}
 
 	// Check for an error
 	if bufErr.String() != "" {
+		result.Failure = check.ContentMismatch
 		result.Message = fmt.Sprintf("Command %s failed : %s", d.Cmd, bufErr.String())
 		return result
 	}
@@ -80,12 +95,14 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Match some content
 	regex, err := regexp.Compile(d.ContentRegex)
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = fmt.Sprintf("Error compiling regex string %s : %s", d.ContentRegex, err)
 		return result
 	}
 
 	// Check if the content matches
 	if !regex.Match(bufOut.Bytes()) {
+		result.Failure = check.ContentMismatch
 		result.Message = "Matching content not found"
 		return result
 	}
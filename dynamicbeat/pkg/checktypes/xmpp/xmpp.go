@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"strconv"
 	"time"
 
@@ -14,6 +15,14 @@ import (
 	"gosrc.io/xmpp"
 )
 
+func init() {
+	check.Register(check.Type{
+		Name:        "xmpp",
+		Description: "Log in to an XMPP server",
+		New:         func() check.Check { return &Definition{} },
+	})
+}
+
 // The Definition configures the behavior of the XMPP check
 // it implements the "check" interface
 type Definition struct {
@@ -33,22 +42,27 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Convert Encrypted to bool
 	encrypted, _ := strconv.ParseBool(d.Encrypted)
 
-	// Create xmpp config
+	// Create xmpp config. The connect timeout is in whole seconds, so make
+	// sure it doesn't round down to zero.
+	timeout := int(check.Remaining(ctx).Seconds())
+	if timeout < 1 {
+		timeout = 1
+	}
 	config := xmpp.Config{
 		TransportConfiguration: xmpp.TransportConfiguration{
-			Address:   fmt.Sprintf("%s:%s", d.Host, d.Port),
+			Address:   net.JoinHostPort(d.Host, d.Port),
 			TLSConfig: &tls.Config{InsecureSkipVerify: true},
-			// ConnectTimeout: 20,
 		},
-		Jid:        fmt.Sprintf("%s@%s", d.Username, d.Host),
-		Credential: xmpp.Password(d.Password),
-		Insecure:   !encrypted,
-		// ConnectTimeout: 20,
+		Jid:            fmt.Sprintf("%s@%s", d.Username, d.Host),
+		Credential:     xmpp.Password(d.Password),
+		Insecure:       !encrypted,
+		ConnectTimeout: timeout,
 	}
 
 	// Create a client
 	client, err := xmpp.NewClient(&config, xmpp.NewRouter(), errorHandler)
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = fmt.Sprintf("Creating a xmpp client failed : %s", err)
 		return result
 	}
@@ -61,6 +75,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 		Id:   "Scorestack-check",
 	})
 	if err != nil {
+		result.Failure = check.DefinitionError
 		result.Message = fmt.Sprintf("Creating IQ message failed : %s", err)
 		return result
 	}
@@ -72,6 +87,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Connect the client
 	err = client.Connect()
 	if err != nil {
+		result.Failure = check.Classify(err, check.Auth)
 		result.Message = fmt.Sprintf("Connecting to %s failed : %s", d.Host, err)
 		return result
 	}
@@ -85,6 +101,7 @@ func (d *Definition) Run(ctx context.Context) check.Result {
 	// Send the IQ message
 	err = client.Send(iq)
 	if err != nil {
+		result.Failure = check.Classify(err, check.Protocol)
 		result.Message = fmt.Sprintf("Sending IQ message to %s failed %s", d.Host, err)
 		return result
 	}
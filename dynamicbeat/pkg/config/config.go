@@ -12,6 +12,11 @@ import (
 
 type Config struct {
 	RoundTime     time.Duration `mapstructure:"round_time"`
+	RoundJitter   time.Duration `mapstructure:"round_jitter"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	Overlap       string        `mapstructure:"overlap"`
+	StartSpread   time.Duration `mapstructure:"start_spread"`
+	Seed          int64         `mapstructure:"seed"`
 	Elasticsearch string        `mapstructure:"elasticsearch"`
 	Username      string        `mapstructure:"username"`
 	Password      string        `mapstructure:"password"`
@@ -27,6 +32,11 @@ type Config struct {
 		Level   int8 `mapstructure:"level"`
 		NoColor bool `mapstructure:"no_color"`
 	} `mapstructure:"log"`
+	MaxConcurrency        int            `mapstructure:"max_concurrency"`
+	MaxConcurrencyPerType map[string]int `mapstructure:"max_concurrency_per_type"`
+	ExecDir               string         `mapstructure:"exec_dir"`
+	ExecEnv               []string       `mapstructure:"exec_env"`
+	FilesDir              string         `mapstructure:"files_dir"`
 }
 
 type Team struct {
@@ -1,17 +1,22 @@
 package dynamicbeat
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
-	"runtime"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/checksource"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/exec"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/config"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/esclient"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/files"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/run"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/scheduler"
 	"go.uber.org/zap"
 )
 
@@ -32,99 +37,153 @@ func Run() error {
 		return err
 	}
 
-	// Connect publisher client
-	/*
-		bt.client, err := b.Publisher.Connect()
-		if err != nil {
-			return err
-		}
-	*/
+	// Checks read these settings while they run, so they're set up once here
+	// instead of reading the configuration in the middle of a round
+	exec.Configure(c.ExecDir, c.ExecEnv)
+	files.Configure(c.FilesDir)
+
+	policy, err := scheduler.ParsePolicy(c.Overlap)
+	if err != nil {
+		return err
+	}
+	if c.RoundJitter >= c.RoundTime {
+		return fmt.Errorf("round_jitter (%s) must be less than round_time (%s)", c.RoundJitter, c.RoundTime)
+	}
+	if c.StartSpread >= c.RoundTime {
+		return fmt.Errorf("start_spread (%s) must be less than round_time (%s)", c.StartSpread, c.RoundTime)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive, got %s", c.Timeout)
+	}
 
-	// Set up CTRL+C handler
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	// Pick a seed if one wasn't configured. The seed is recorded in each
+	// round summary so that rounds can be reproduced later on.
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	zap.S().Infof("Using random seed %d", seed)
+
+	// Set up handler for CTRL+C and termination signals
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Get initial check definitions
-	var defs []check.Config
-	doubleBreak := false
-	// TODO: Find a better way for looping until we can hit Elasticsearch
+	defs := &definitions{}
 	zap.S().Infof("Getting initial check definitions...")
 	for {
+		err = defs.refresh(es)
+		if err == nil {
+			break
+		}
+
+		zap.S().Infof("Failed to reach Elasticsearch. Waiting 5 seconds to try again...")
+		zap.S().Debugf("Connection error was: %s", err)
 		select {
-		// Case for catching Ctrl+C and gracfully exiting
-		case <-quit:
+		// Case for catching signals and gracefully exiting
+		case <-ctx.Done():
 			return nil
-		default:
-			// Continue looping and sleeping till we can hit Elasticsearch
-			defs, err = es.LoadAll()
-			if err != nil {
-				zap.S().Infof("Failed to reach Elasticsearch. Waiting 5 seconds to try again...")
-				zap.S().Debugf("dynamicbeat", "Connection error was: %s", err)
-				time.Sleep(5 * time.Second)
-			} else {
-				doubleBreak = true
-				break
-			}
-		}
-		// TODO: Find a better way of breaking the for loop if we break from switch
-		// Needed to break out of the for loop
-		if doubleBreak {
-			break
+		case <-time.After(5 * time.Second):
 		}
 	}
 
-	// Start publisher goroutine
+	// Start publisher goroutines
 	results := make(chan check.Result)
 	published := make(chan uint64)
-	go publishEvents(pub, results, published)
+	go publishResults(pub, results, published)
+	events := make(chan scheduler.Event, 16)
+	recorded := make(chan uint64)
+	go publishEvents(pub, events, recorded)
 
-	// Start running checks
-	ticker := time.NewTicker(c.RoundTime)
-
-	var wg sync.WaitGroup
-	for {
-		select {
-		case <-quit:
-			// Wait for all checks.RunChecks goroutines to exit
-			wg.Wait()
-
-			// Close the event publishing queue so the publishEvents goroutine will exit
-			close(results)
+	// Continue numbering rounds from wherever the last run left off
+	last, err := pub.LastRound()
+	if err != nil {
+		zap.S().Warnf("Failed to find the last round number, starting from round 1: %s", err)
+	}
 
-			// Wait for all events to be published
-			<-published
-			close(published)
-			return nil
-		case <-ticker.C:
-			zap.S().Infof("Number of goroutines: %d", runtime.NumGoroutine())
-			zap.S().Infof("Starting a series of %d checks", len(defs))
-
-			// Start the goroutine
-			started := make(chan bool)
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				run.Round(defs, results, started)
-			}()
-
-			// Wait until all the checks have been started before we refresh
-			// the checks from Elasticsearch to make sure that we don't
-			// overwrite the check definitions while they're in use.
-			// TODO: determine if it's possible to overwrite the defs while
-			// they're in use by the above function
+	// Start running checks
+	opts := run.Options{
+		Timeout: c.Timeout,
+		Pool: run.NewPool(run.Limits{
+			Max:   c.MaxConcurrency,
+			Types: c.MaxConcurrencyPerType,
+		}),
+		Spread: c.StartSpread,
+		Seed:   seed,
+	}
+	sched := &scheduler.Scheduler{
+		Interval: c.RoundTime,
+		Jitter:   c.RoundJitter,
+		Seed:     seed,
+		Policy:   policy,
+		Events:   events,
+		Last:     last,
+	}
+	sched.Run(ctx, func(round uint64) {
+		current := defs.get()
+		zap.S().Infof("Starting round %d of %d checks", round, len(current))
+
+		// Once all the checks have been started, update the check
+		// definitions for the next round. The definitions for this round
+		// won't be changed since we're holding our own copy.
+		started := make(chan bool)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 			<-started
-			zap.S().Infof("Started series of checks")
+			zap.S().Infof("Started round %d", round)
 
-			// Update the check definitions for the next round
-			defs, err = es.LoadAll()
+			err := defs.refresh(es)
 			if err != nil {
 				zap.S().Warnf("Failed to update check definitions : %s", err)
 			}
+		}()
+
+		summary := run.Round(round, current, opts, results, started)
+		wg.Wait()
+
+		err := pub.AddSummary(summary)
+		if err != nil {
+			zap.S().Errorf("failed to index summary of round %d: %s", round, err)
 		}
+	})
+
+	// Close the publishing queues so the publisher goroutines will exit, then
+	// wait for everything to be published
+	close(results)
+	close(events)
+	<-published
+	<-recorded
+	return nil
+}
+
+// definitions holds the most recently loaded check definitions so that they
+// can be safely refreshed while a round is running.
+type definitions struct {
+	defs []check.Config
+	mu   sync.Mutex
+}
+
+func (d *definitions) get() []check.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.defs
+}
+
+func (d *definitions) refresh(es *checksource.Elasticsearch) error {
+	defs, err := es.LoadAll()
+	if err != nil {
+		return err
 	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.defs = defs
+	return nil
 }
 
-func publishEvents(es *esclient.Client, results <-chan check.Result, out chan<- uint64) {
+func publishResults(es *esclient.Client, results <-chan check.Result, out chan<- uint64) {
 	published := uint64(0)
 	for result := range results {
 		err := es.AddResult(result)
@@ -137,3 +196,16 @@ func publishEvents(es *esclient.Client, results <-chan check.Result, out chan<-
 	}
 	out <- published
 }
+
+func publishEvents(es *esclient.Client, events <-chan scheduler.Event, out chan<- uint64) {
+	recorded := uint64(0)
+	for event := range events {
+		err := es.AddEvent(event)
+		if err != nil {
+			zap.S().Errorf("failed to record scheduler event: %s", err)
+		} else {
+			recorded++
+		}
+	}
+	out <- recorded
+}
@@ -0,0 +1,21 @@
+package esclient
+
+import (
+	"fmt"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/scheduler"
+)
+
+func (c *Client) AddEvent(event scheduler.Event) error {
+	index, body, err := event.Document()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.Index(index, body)
+	if err != nil {
+		return fmt.Errorf("failed to index %s event for round %d: %s", event.Type, event.Round, err)
+	}
+
+	return c.CloseAndCheck(res)
+}
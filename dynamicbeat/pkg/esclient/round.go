@@ -0,0 +1,62 @@
+package esclient
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/run"
+)
+
+func (c *Client) AddSummary(summary run.Summary) error {
+	index, body, err := summary.Document()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.Index(index, body)
+	if err != nil {
+		return fmt.Errorf("failed to index summary of round %d: %s", summary.Round, err)
+	}
+
+	return c.CloseAndCheck(res)
+}
+
+// LastRound finds the number of the most recent round recorded in
+// Elasticsearch. Skipped rounds don't have a summary, but they do have an
+// event, so both the round summaries and the scheduler events are searched.
+// If there are neither, 0 is returned.
+func (c *Client) LastRound() (uint64, error) {
+	res, err := c.Search(
+		c.Search.WithIndex("rounds", "events"),
+		c.Search.WithSize(1),
+		c.Search.WithSort("round:desc"),
+		c.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for the last round: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return 0, fmt.Errorf("failed to search for the last round: %s", res.String())
+	}
+
+	// Decode JSON response into struct
+	docs := struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					Round uint64 `json:"round"`
+				} `json:"_source"`
+			}
+		}
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&docs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode last round search results: %s", err)
+	}
+
+	if len(docs.Hits.Hits) == 0 {
+		return 0, nil
+	}
+	return docs.Hits.Hits[0].Source.Round, nil
+}
@@ -0,0 +1,110 @@
+package esclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/assets/roles"
+)
+
+// readable returns the indices the dynamicbeat role is allowed to read.
+func readable(t *testing.T) map[string]bool {
+	role := struct {
+		Elasticsearch struct {
+			Indices []struct {
+				Names      []string `json:"names"`
+				Privileges []string `json:"privileges"`
+			} `json:"indices"`
+		} `json:"elasticsearch"`
+	}{}
+	err := json.NewDecoder(roles.Dynamicbeat()).Decode(&role)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indices := make(map[string]bool)
+	for _, i := range role.Elasticsearch.Indices {
+		for _, p := range i.Privileges {
+			if p == "read" {
+				for _, name := range i.Names {
+					indices[name] = true
+				}
+			}
+		}
+	}
+
+	return indices
+}
+
+// serveSearch starts a stand-in Elasticsearch server that holds documents
+// with the given round numbers in each index. Like Elasticsearch, searches
+// that ignore unavailable indices silently skip the ones the dynamicbeat role
+// can't read.
+func serveSearch(t *testing.T, docs map[string][]uint64) *Client {
+	allowed := readable(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(path) != 2 || path[1] != "_search" || r.URL.Query().Get("sort") != "round:desc" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		hits := []string{}
+		var last uint64
+		for _, index := range strings.Split(path[0], ",") {
+			if !allowed[index] {
+				if r.URL.Query().Get("ignore_unavailable") == "true" {
+					continue
+				}
+				http.Error(w, "action unauthorized", http.StatusForbidden)
+				return
+			}
+			for _, round := range docs[index] {
+				if round > last {
+					last = round
+				}
+			}
+		}
+		if last > 0 {
+			hits = append(hits, fmt.Sprintf(`{"_source":{"round":%d}}`, last))
+		}
+		fmt.Fprintf(w, `{"hits":{"hits":[%s]}}`, strings.Join(hits, ","))
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := New(server.URL, "dynamicbeat", "changeme", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestLastRound(t *testing.T) {
+	cases := []struct {
+		name string
+		docs map[string][]uint64
+		want uint64
+	}{
+		{"Empty", map[string][]uint64{}, 0},
+		{"Rounds", map[string][]uint64{"rounds": {1, 2, 3}}, 3},
+		{"SkippedRound", map[string][]uint64{"rounds": {1, 2, 3}, "events": {2, 4}}, 4},
+		{"OnlyEvents", map[string][]uint64{"events": {1}}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			last, err := serveSearch(t, c.docs).LastRound()
+			if err != nil {
+				t.Fatalf("LastRound() error = %s", err)
+			}
+			if last != c.want {
+				t.Errorf("LastRound() = %d, want %d", last, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package files gives checks access to files on the host running Dynamicbeat,
+// without letting check definitions read anything outside of the directories
+// configured for that purpose.
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dir is the files_dir setting. It's set once at startup by Configure, so
+// that checks don't read the configuration while they run.
+var dir string
+
+// Configure sets the directory that checks may read files from.
+func Configure(filesDir string) {
+	dir = filesDir
+}
+
+// Read reads a file from the files_dir directory.
+func Read(name string) ([]byte, error) {
+	path, err := Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// Path finds the full path to a file in the files_dir directory, for checks
+// that need to open the file themselves.
+func Path(name string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("reading files is disabled because files_dir is not set")
+	}
+
+	return Resolve(dir, name)
+}
+
+// Resolve finds the full path to a file within a directory. Paths that try to
+// escape the directory are kept within it.
+func Resolve(dir string, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Clean(string(filepath.Separator)+name))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,66 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"index.html", filepath.Join("certs", "ca.pem")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{"index.html", "index.html", false},
+		{"certs/ca.pem", "certs/ca.pem", false},
+		{"/certs/ca.pem", "certs/ca.pem", false},
+		{"../index.html", "index.html", false},
+		{"certs/../../../index.html", "index.html", false},
+		{"certs", "", true},
+		{"missing.txt", "", true},
+		{"../../etc/passwd", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Resolve(dir, c.name)
+			if (err != nil) != c.err {
+				t.Fatalf("Resolve() error = %v, want error: %t", err, c.err)
+			}
+			if want := filepath.Join(dir, filepath.FromSlash(c.want)); !c.err && got != want {
+				t.Errorf("Resolve() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	Configure("")
+	if _, err := Read("index.html"); err == nil {
+		t.Error("Read() allowed a file without files_dir set")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>Hello</h1>"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	Configure(dir)
+	defer Configure("")
+
+	data, err := Read("index.html")
+	if err != nil || string(data) != "<h1>Hello</h1>" {
+		t.Errorf("Read() = %q, %v, want the file's contents", data, err)
+	}
+}
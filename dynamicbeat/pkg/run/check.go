@@ -12,25 +12,74 @@ import (
 
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes"
+	"go.uber.org/zap"
 )
 
 func Check(ctx context.Context, def check.Config) check.Result {
-	// Create a check from the definition
-	chk, err := unpackDef(def)
+	start := time.Now()
+	if def.Retry != nil {
+		err := def.Retry.Validate()
+		if err != nil {
+			r := invalid(def, fmt.Sprintf("encountered an error when parsing check retry policy: %s", err))
+			r.Attempts = 1
+			r.Total = time.Since(start)
+			return r
+		}
+	}
+
+	// Without a retry policy, the check only gets a single attempt
+	if def.Retry == nil || def.Retry.Attempts <= 1 {
+		r := attempt(ctx, def)
+		r.Attempts = 1
+		r.Total = time.Since(start)
+		return r
+	}
+
+	backoff, err := def.Retry.GetBackoff()
 	if err != nil {
-		return check.Result{
-			Timestamp: time.Now(),
-			Metadata:  def.Metadata,
-			Passed:    false,
-			Message:   fmt.Sprintf("encountered an error when unpacking check definition: %s", err),
-			Details:   nil,
+		zap.S().Warnf("[%s] Using default retry backoff of %s: %s", def.ID, backoff, err)
+	}
+
+	messages := make([]string, 0, def.Retry.Attempts)
+	for i := 1; ; i++ {
+		r := attempt(ctx, def)
+		messages = append(messages, r.Message)
+
+		// Stop if the check passed, if we're out of attempts or time, or if
+		// the failure isn't one that should be retried
+		if r.Passed || i >= def.Retry.Attempts || ctx.Err() != nil || !retriable(def.Retry, r) {
+			return withAttempts(r, messages, start)
 		}
+
+		zap.S().Debugf("[%s] Attempt %d failed, retrying in %s: %s", def.ID, i, backoff, r.Message)
+		select {
+		case <-ctx.Done():
+			return withAttempts(timedOut(def), messages, start)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt runs a single instance of a check.
+func attempt(ctx context.Context, def check.Config) check.Result {
+	// Create a check from the definition. This is done for every attempt,
+	// since running a check may modify its definition.
+	chk, err := unpackDef(def)
+	if err != nil {
+		return invalid(def, fmt.Sprintf("encountered an error when unpacking check definition: %s", err))
+	}
+
+	thresholds, err := def.SLO.Thresholds()
+	if err != nil {
+		return invalid(def, fmt.Sprintf("encountered an error when parsing check SLO: %s", err))
 	}
 
 	// Set up the channel to recieve the CheckResult from the Check
 	result := make(chan check.Result, 1)
 
 	// Run the check
+	start := time.Now()
 	go func() {
 		result <- chk.Run(ctx)
 	}()
@@ -39,33 +88,67 @@ func Check(ctx context.Context, def check.Config) check.Result {
 	for {
 		select {
 		case <-ctx.Done():
-			// We already initialized the event with the correct values for a
-			// context timeout, so just return that.
-			return check.Result{
-				Timestamp: time.Now(),
-				Metadata:  def.Metadata,
-				Passed:    false,
-				Message:   "check timed out",
-				Details:   nil,
-			}
+			r := timedOut(def)
+			r.Duration = time.Since(start)
+			return r
 		case r := <-result:
 			close(result)
+			r.Duration = time.Since(start)
+			check.Enforce(&r, thresholds)
 			return r
 		}
 	}
 }
 
+func invalid(def check.Config, msg string) check.Result {
+	return check.Result{
+		Timestamp: time.Now(),
+		Metadata:  def.Metadata,
+		Passed:    false,
+		Failure:   check.DefinitionError,
+		Message:   msg,
+		Details:   nil,
+	}
+}
+
+func timedOut(def check.Config) check.Result {
+	return check.Result{
+		Timestamp: time.Now(),
+		Metadata:  def.Metadata,
+		Passed:    false,
+		Failure:   check.Timeout,
+		Message:   "check timed out",
+		Details:   nil,
+	}
+}
+
 func unpackDef(config check.Config) (check.Check, error) {
+	def, err := checktypes.GetCheckType(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Render any template strings in the definition
 	var renderedJSON []byte
 	templ := template.New("definition")
-	templ, err := templ.Parse(string(config.Definition))
+	templ, err = templ.Parse(string(config.Definition))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse template for check: %s", err.Error())
 	}
 
+	// Values the check fills in itself aren't known yet, so they're left as
+	// references for the check to expand
+	attributes := config.Attributes.Merged()
+	if runtime, ok := def.(check.RuntimeChecker); ok {
+		for _, name := range runtime.RuntimeVariables() {
+			if _, ok := attributes[name]; !ok {
+				attributes[name] = fmt.Sprintf("${%s}", name)
+			}
+		}
+	}
+
 	var buf bytes.Buffer
-	err = templ.Execute(&buf, config.Attributes.Merged())
+	err = templ.Execute(&buf, attributes)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to execute template for check: %s", err.Error())
 	}
@@ -73,7 +156,6 @@ func unpackDef(config check.Config) (check.Check, error) {
 	renderedJSON = buf.Bytes()
 
 	// Create a Definition from the rendered JSON string
-	def := checktypes.GetCheckType(config)
 	err = initCheck(config, renderedJSON, def)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unpack definition and apply attributes to check: %s", err)
@@ -0,0 +1,55 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/http"
+	noopcheck "github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes/noop"
+)
+
+func TestUnpackDef(t *testing.T) {
+	cases := []struct {
+		name       string
+		attributes map[string]string
+		want       string
+	}{
+		{"SavedValue", map[string]string{"host": "web.team01.local"}, "/items/${SavedValue}"},
+		{"Attribute", map[string]string{"host": "web.team01.local", "SavedValue": "7"}, "/items/7"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := check.Config{
+				Metadata:   check.Metadata{ID: "http-team01", Type: "http"},
+				Definition: []byte(`{"Requests": [{"Host": "{{.host}}", "Path": "/items/{{.SavedValue}}"}]}`),
+				Attributes: check.Attributes{User: c.attributes},
+			}
+
+			chk, err := unpackDef(def)
+			if err != nil {
+				t.Fatalf("unpackDef() error = %s", err)
+			}
+			r := chk.(*http.Definition).Requests[0]
+			if r.Host != "web.team01.local" || r.Path != c.want {
+				t.Errorf("request is for %s%s, want web.team01.local%s", r.Host, r.Path, c.want)
+			}
+		})
+	}
+}
+
+func TestUnpackDefRuntimeVariables(t *testing.T) {
+	// Only check types that fill in SavedValue themselves get a reference to it
+	def := check.Config{
+		Metadata:   check.Metadata{ID: "noop-team01", Type: "noop"},
+		Definition: []byte(`{"Dynamic": "{{.SavedValue}}", "Static": "static"}`),
+	}
+
+	chk, err := unpackDef(def)
+	if err != nil {
+		t.Fatalf("unpackDef() error = %s", err)
+	}
+	if dynamic := chk.(*noopcheck.Definition).Dynamic; dynamic == "${SavedValue}" {
+		t.Errorf("Dynamic = %q, want the template to be rendered without a reference", dynamic)
+	}
+}
@@ -0,0 +1,51 @@
+package run
+
+// Limits configures how many checks may run at the same time.
+type Limits struct {
+	Max   int            // the maximum number of checks running at once, or 0 for no limit
+	Types map[string]int // the maximum number of checks of each type running at once
+}
+
+// A Pool limits the number of checks that can run at the same time, both
+// overall and for each check type. A single Pool is shared by every round so
+// that the limits hold even when rounds overlap.
+type Pool struct {
+	global chan struct{}
+	types  map[string]chan struct{}
+}
+
+func NewPool(l Limits) *Pool {
+	p := &Pool{types: make(map[string]chan struct{})}
+	if l.Max > 0 {
+		p.global = make(chan struct{}, l.Max)
+	}
+	for typ, max := range l.Types {
+		if max > 0 {
+			p.types[typ] = make(chan struct{}, max)
+		}
+	}
+
+	return p
+}
+
+// acquire blocks until a check of the given type is allowed to run. The type
+// slot is acquired before the global slot so that checks waiting on a busy
+// check type don't prevent other types of checks from running.
+func (p *Pool) acquire(typ string) {
+	if sem, ok := p.types[typ]; ok {
+		sem <- struct{}{}
+	}
+	if p.global != nil {
+		p.global <- struct{}{}
+	}
+}
+
+// release frees up the slots that were acquired for a check of the given type.
+func (p *Pool) release(typ string) {
+	if p.global != nil {
+		<-p.global
+	}
+	if sem, ok := p.types[typ]; ok {
+		<-sem
+	}
+}
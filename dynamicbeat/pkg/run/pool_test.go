@@ -0,0 +1,73 @@
+package run
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	cases := []struct {
+		name   string
+		limits Limits
+		checks map[string]int // how many checks of each type to run
+		max    int            // the most checks that may run at once
+		types  map[string]int // the most checks of each type that may run at once
+	}{
+		{"Unlimited", Limits{}, map[string]int{"http": 8}, 8, nil},
+		{"Global", Limits{Max: 3}, map[string]int{"http": 5, "dns": 5}, 3, nil},
+		{"Type", Limits{Types: map[string]int{"ssh": 2}}, map[string]int{"ssh": 6, "dns": 4}, 6, map[string]int{"ssh": 2}},
+		{"Both", Limits{Max: 4, Types: map[string]int{"ssh": 1}}, map[string]int{"ssh": 4, "dns": 6}, 4, map[string]int{"ssh": 1}},
+		{"ZeroTypeLimit", Limits{Types: map[string]int{"ssh": 0}}, map[string]int{"ssh": 3}, 3, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewPool(c.limits)
+
+			var mu sync.Mutex
+			running, peak := 0, 0
+			types, typePeaks := make(map[string]int), make(map[string]int)
+
+			var wg sync.WaitGroup
+			for typ, n := range c.checks {
+				for i := 0; i < n; i++ {
+					wg.Add(1)
+					go func(typ string) {
+						defer wg.Done()
+						p.acquire(typ)
+						defer p.release(typ)
+
+						mu.Lock()
+						running++
+						types[typ]++
+						if running > peak {
+							peak = running
+						}
+						if types[typ] > typePeaks[typ] {
+							typePeaks[typ] = types[typ]
+						}
+						mu.Unlock()
+
+						time.Sleep(10 * time.Millisecond)
+
+						mu.Lock()
+						running--
+						types[typ]--
+						mu.Unlock()
+					}(typ)
+				}
+			}
+			wg.Wait()
+
+			if peak > c.max {
+				t.Errorf("%d checks ran at once, want at most %d", peak, c.max)
+			}
+			for typ, max := range c.types {
+				if typePeaks[typ] > max {
+					t.Errorf("%d %s checks ran at once, want at most %d", typePeaks[typ], typ, max)
+				}
+			}
+		})
+	}
+}
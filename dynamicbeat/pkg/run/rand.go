@@ -0,0 +1,41 @@
+package run
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// NewRand creates a random number generator for a single round. The same seed
+// and round number will always produce the same generator, so the order and
+// start times of the checks in a round can be reproduced later on.
+func NewRand(seed int64, round uint64) *rand.Rand {
+	h := fnv.New64a()
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], uint64(seed))
+	binary.BigEndian.PutUint64(buf[8:], round)
+	_, _ = h.Write(buf)
+
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// shuffle returns a copy of the check definitions in a random order. The
+// definitions are sorted by ID before they are shuffled so that the order
+// only depends on the random number generator, and not on the order the
+// definitions were loaded in.
+func shuffle(rng *rand.Rand, defs []check.Config) []check.Config {
+	out := make([]check.Config, len(defs))
+	copy(out, defs)
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	rng.Shuffle(len(out), func(i, j int) {
+		out[i], out[j] = out[j], out[i]
+	})
+
+	return out
+}
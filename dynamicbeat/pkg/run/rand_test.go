@@ -0,0 +1,56 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestNewRand(t *testing.T) {
+	a, b := NewRand(42, 7), NewRand(42, 7)
+	for i := 0; i < 10; i++ {
+		if x, y := a.Int63(), b.Int63(); x != y {
+			t.Fatalf("generators with the same seed and round diverged: %d != %d", x, y)
+		}
+	}
+
+	first := NewRand(42, 7).Int63()
+	if NewRand(42, 8).Int63() == first {
+		t.Error("generators for different rounds produced the same value")
+	}
+	if NewRand(43, 7).Int63() == first {
+		t.Error("generators for different seeds produced the same value")
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	var defs []check.Config
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		defs = append(defs, check.Config{Metadata: check.Metadata{ID: id}})
+	}
+	reversed := make([]check.Config, len(defs))
+	for i, def := range defs {
+		reversed[len(defs)-1-i] = def
+	}
+
+	got := ids(shuffle(NewRand(1, 1), defs))
+	if again := ids(shuffle(NewRand(1, 1), reversed)); !reflect.DeepEqual(got, again) {
+		t.Errorf("order depends on how the definitions were loaded: %v != %v", got, again)
+	}
+	if defs[0].ID != "a" || reversed[0].ID != "h" {
+		t.Error("shuffle modified the definitions it was given")
+	}
+	if len(got) != len(defs) {
+		t.Errorf("shuffle returned %d definitions, want %d", len(got), len(defs))
+	}
+}
+
+func ids(defs []check.Config) []string {
+	out := make([]string, len(defs))
+	for i, def := range defs {
+		out[i] = def.ID
+	}
+
+	return out
+}
@@ -0,0 +1,42 @@
+package run
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// retriable determines if a failed check should be run again according to
+// the retry policy.
+func retriable(policy *check.Retry, r check.Result) bool {
+	if len(policy.On) == 0 {
+		return true
+	}
+
+	for _, on := range policy.On {
+		if check.Failure(on) == r.Failure {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withAttempts records the number of attempts, the total time they took since
+// the start of the first attempt, and the message from each attempt.
+func withAttempts(r check.Result, messages []string, start time.Time) check.Result {
+	r.Attempts = len(messages)
+	r.Total = time.Since(start)
+	if r.Details == nil {
+		r.Details = make(map[string]string)
+	}
+
+	r.Details["attempts"] = strconv.Itoa(len(messages))
+	for i, msg := range messages {
+		r.Details[fmt.Sprintf("attempt_%d", i+1)] = msg
+	}
+
+	return r
+}
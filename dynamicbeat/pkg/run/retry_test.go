@@ -0,0 +1,92 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+func TestRetriable(t *testing.T) {
+	cases := []struct {
+		name    string
+		on      []string
+		failure check.Failure
+		want    bool
+	}{
+		{"AllFailures", nil, check.Protocol, true},
+		{"Listed", []string{"timeout", "connect_refused"}, check.ConnectRefused, true},
+		{"NotListed", []string{"timeout", "connect_refused"}, check.Auth, false},
+		{"DefinitionError", []string{"timeout"}, check.DefinitionError, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := retriable(&check.Retry{On: c.on}, check.Result{Failure: c.failure})
+			if got != c.want {
+				t.Errorf("retriable() = %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWithAttempts(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+	r := withAttempts(check.Result{Details: map[string]string{"rows": "3"}}, []string{"refused", "timed out"}, start)
+
+	if r.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", r.Attempts)
+	}
+	if r.Total < time.Second {
+		t.Errorf("Total = %s, want at least 1s", r.Total)
+	}
+	want := map[string]string{"rows": "3", "attempts": "2", "attempt_1": "refused", "attempt_2": "timed out"}
+	for k, v := range want {
+		if r.Details[k] != v {
+			t.Errorf("Details[%s] = %q, want %q", k, r.Details[k], v)
+		}
+	}
+}
+
+func TestCheckRetries(t *testing.T) {
+	// Without a value for Dynamic, noop checks fail with a definition error
+	failing := check.Config{
+		Metadata:   check.Metadata{ID: "noop-team01", Type: "noop"},
+		Definition: []byte(`{"Static": "b"}`),
+	}
+
+	cases := []struct {
+		name     string
+		def      check.Config
+		retry    *check.Retry
+		attempts int
+		failure  check.Failure
+	}{
+		{"NoPolicy", failing, nil, 1, check.DefinitionError},
+		{"Retried", failing, &check.Retry{Attempts: 3, Backoff: "1ms"}, 3, check.DefinitionError},
+		{"NotRetriable", failing, &check.Retry{Attempts: 3, Backoff: "1ms", On: []string{"timeout"}}, 1, check.DefinitionError},
+		{"Passed", noop("noop-team01", "team01", 1), &check.Retry{Attempts: 3, Backoff: "1ms"}, 1, check.None},
+		{"UnknownFailure", noop("noop-team01", "team01", 1), &check.Retry{Attempts: 3, Backoff: "1ms", On: []string{"timed_out"}}, 1, check.DefinitionError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			def := c.def
+			def.Retry = c.retry
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := Check(ctx, def)
+			if r.Attempts != c.attempts {
+				t.Errorf("Attempts = %d, want %d", r.Attempts, c.attempts)
+			}
+			if r.Failure != c.failure {
+				t.Errorf("Failure = %q, want %q", r.Failure, c.failure)
+			}
+			if r.Total <= 0 {
+				t.Errorf("Total = %s, want a positive duration", r.Total)
+			}
+		})
+	}
+}
@@ -9,60 +9,118 @@ import (
 	"go.uber.org/zap"
 )
 
+// Options configures how the checks in a round are run.
+type Options struct {
+	Timeout time.Duration // the timeout for checks that don't set their own
+	Pool    *Pool         // limits how many checks can run at the same time, across all rounds
+	Spread  time.Duration // checks will start at random times within this window
+	Seed    int64         // seeds the random check order and start times
+}
+
 // Round : Run a course of checks based on the currently-loaded configuration.
-func Round(defs []check.Config, results chan<- check.Result, started chan<- bool) {
+// Each check is given its own timeout, or the default timeout if the check
+// doesn't set one. Every result is stamped with the round number. Once all
+// the checks have finished, a summary of the round is returned.
+func Round(round uint64, defs []check.Config, opts Options, results chan<- check.Result, started chan<- bool) Summary {
 	start := time.Now()
+	summary := newSummary(round, start)
+	p := opts.Pool
+	if p == nil {
+		p = NewPool(Limits{})
+	}
+
+	// Run the checks in a random order that can be reproduced from the seed
+	rng := NewRand(opts.Seed, round)
+	summary.Seed = opts.Seed
 
 	// Make an event queue separate from the publisher queue so we can track
 	// which checks are still running
-	finished := make(chan check.Result, len(defs))
+	type finishedCheck struct {
+		result   check.Result
+		timedOut bool
+	}
+	finished := make(chan finishedCheck, len(defs))
 
 	// Iterate over each check
-	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
-	defer cancel()
 	names := make(map[string]bool)
 	var wg sync.WaitGroup
-	for _, d := range defs {
+	for _, d := range shuffle(rng, defs) {
 		// Start check goroutine
 		names[d.ID] = false
 		wg.Add(1)
 
+		// Pick a random time within the spread window to start the check
+		var offset time.Duration
+		if opts.Spread > 0 {
+			offset = time.Duration(rng.Int63n(int64(opts.Spread)))
+		}
+		scheduled := start.Add(offset)
+
 		def := d
 		go func() {
 			defer wg.Done()
 
+			// Wait until the check's start time, and then for the pool to
+			// allow the check to run
+			time.Sleep(time.Until(scheduled))
+			p.acquire(def.Type)
+			defer p.release(def.Type)
+			queueWait := time.Since(scheduled)
+
+			// The check's timeout becomes the deadline for everything the
+			// check does. The timeout doesn't start until the check is
+			// actually running, so time spent in the queue isn't counted.
+			t, err := def.GetTimeout(opts.Timeout)
+			if err != nil {
+				zap.S().Warnf("[%s] Using default timeout of %s: %s", def.ID, opts.Timeout, err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), t)
+			defer cancel()
+
 			checkStart := time.Now()
 			result := Check(ctx, def)
-			zap.S().Debugf("[%s] Finished after %.2f seconds", result.ID, time.Since(checkStart).Seconds())
-			finished <- result
+			execution := time.Since(checkStart)
+			result.Round = round
+			zap.S().Debugf("[%s] Finished after %.2f seconds", result.ID, execution.Seconds())
+
+			// Report queue wait time separately from execution time
+			result.Record("start_offset", offset)
+			result.Record("queue_wait", queueWait)
+			result.Record("execution", execution)
+			finished <- finishedCheck{result, !result.Passed && ctx.Err() == context.DeadlineExceeded}
 		}()
 	}
 
 	// Signal that all checks have started
 	started <- true
 
-	// Wait for checks to finish
-	defer wg.Wait()
-	// zap.S().Infof("Checks started at %s have finished in %.2f seconds", start.Format("15:04:05.000"), time.Since(start).Seconds())
+	// Close the queue once all the checks have finished
 	go func() {
-		for {
-			if len(names) == 0 {
-				break
-			} else {
-				time.Sleep(30 * time.Second)
-				if len(names) > 0 {
-					zap.S().Warnf("Checks still running after %.2f seconds: %+v", time.Since(start).Seconds(), names)
-				}
-			}
-		}
-		zap.S().Infof("All checks started %.2f seconds ago have finished", time.Since(start).Seconds())
+		wg.Wait()
 		close(finished)
 	}()
-	for result := range finished {
-		// Record that the check has finished
-		delete(names, result.ID)
 
-		// Publish the event to the publisher queue
-		results <- result
+	// Wait for checks to finish, periodically reporting any that are taking
+	// a long time
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			zap.S().Warnf("Checks still running after %.2f seconds: %+v", time.Since(start).Seconds(), names)
+		case f, ok := <-finished:
+			if !ok {
+				zap.S().Infof("All checks started %.2f seconds ago have finished", time.Since(start).Seconds())
+				summary.End = time.Now()
+				return summary
+			}
+
+			// Record that the check has finished
+			delete(names, f.result.ID)
+			summary.add(f.result, f.timedOut)
+
+			// Publish the event to the publisher queue
+			results <- f.result
+		}
 	}
 }
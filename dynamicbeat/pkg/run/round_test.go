@@ -0,0 +1,88 @@
+package run
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// noop creates the definition of a noop check, which always passes.
+func noop(id string, group string, weight int64) check.Config {
+	return check.Config{
+		Metadata:   check.Metadata{ID: id, Type: "noop", Group: group, ScoreWeight: weight},
+		Definition: []byte(`{"Dynamic": "a", "Static": "b"}`),
+	}
+}
+
+func TestRound(t *testing.T) {
+	defs := []check.Config{
+		noop("web-team01", "team01", 2),
+		noop("dns-team01", "team01", 1),
+		noop("web-team02", "team02", 2),
+		{Metadata: check.Metadata{ID: "bad-team02", Type: "noop", Group: "team02", ScoreWeight: 5}, Definition: []byte(`{}`)},
+	}
+	results := make(chan check.Result, len(defs))
+	started := make(chan bool, 1)
+
+	summary := Round(7, defs, Options{Timeout: time.Second, Seed: 1}, results, started)
+	close(results)
+
+	if !<-started {
+		t.Error("round did not signal that it started")
+	}
+	for r := range results {
+		if r.Round != 7 {
+			t.Errorf("result for %s has round %d, want 7", r.ID, r.Round)
+		}
+		for _, phase := range []string{"start_offset", "queue_wait", "execution"} {
+			if _, ok := r.Timings[phase]; !ok {
+				t.Errorf("result for %s has no %s timing", r.ID, phase)
+			}
+		}
+	}
+
+	if summary.Round != 7 || summary.Seed != 1 {
+		t.Errorf("summary has round %d and seed %d, want 7 and 1", summary.Round, summary.Seed)
+	}
+	if summary.Checks != 4 || summary.Passed != 3 || summary.Failed != 1 || summary.TimedOut != 0 {
+		t.Errorf("summary counted %d checks, %d passed, %d failed, and %d timed out, want 4, 3, 1, and 0", summary.Checks, summary.Passed, summary.Failed, summary.TimedOut)
+	}
+	if summary.Scores["team01"] != 3 || summary.Scores["team02"] != 2 {
+		t.Errorf("summary scores = %v, want team01: 3 and team02: 2", summary.Scores)
+	}
+	if summary.End.Before(summary.Start) {
+		t.Errorf("summary ends at %s, before it starts at %s", summary.End, summary.Start)
+	}
+}
+
+func TestSummaryDocument(t *testing.T) {
+	start := time.Date(2021, 9, 28, 12, 0, 0, 0, time.UTC)
+	s := newSummary(3, start)
+	s.End = start.Add(1500 * time.Millisecond)
+	s.add(check.Result{Metadata: check.Metadata{Group: "team01", ScoreWeight: 4}, Passed: true}, false)
+	s.add(check.Result{Metadata: check.Metadata{Group: "team02", ScoreWeight: 4}}, true)
+
+	index, body, err := s.Document()
+	if err != nil {
+		t.Fatalf("Document() error = %s", err)
+	}
+	if index != "rounds" {
+		t.Errorf("index = %s, want rounds", index)
+	}
+
+	b, _ := io.ReadAll(body)
+	var doc summaryDoc
+	err = json.Unmarshal(b, &doc)
+	if err != nil {
+		t.Fatalf("failed to decode summary document: %s", err)
+	}
+	if doc.Round != 3 || doc.Duration != 1.5 || doc.Checks != 2 || doc.Passed != 1 || doc.Failed != 1 || doc.TimedOut != 1 {
+		t.Errorf("unexpected summary document: %s", b)
+	}
+	if doc.Scores["team01"] != 4 || doc.Scores["team02"] != 0 {
+		t.Errorf("scores = %v, want team01: 4 and team02: 0", doc.Scores)
+	}
+}
@@ -0,0 +1,92 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/check"
+)
+
+// A Summary describes the outcome of a single round of checks.
+type Summary struct {
+	Round    uint64
+	Seed     int64
+	Start    time.Time
+	End      time.Time
+	Checks   int
+	Passed   int
+	Failed   int
+	TimedOut int
+	Scores   map[string]int64 // points earned by each group during the round
+}
+
+func newSummary(round uint64, start time.Time) Summary {
+	return Summary{
+		Round:  round,
+		Start:  start,
+		Scores: make(map[string]int64),
+	}
+}
+
+// add records a check result in the summary. Timed out checks are counted as
+// both failed and timed out.
+func (s *Summary) add(result check.Result, timedOut bool) {
+	s.Checks++
+
+	// Make sure every group shows up in the summary, even if it didn't earn
+	// any points this round
+	if _, exists := s.Scores[result.Group]; !exists {
+		s.Scores[result.Group] = 0
+	}
+
+	if result.Passed {
+		s.Passed++
+		s.Scores[result.Group] += result.ScoreWeight
+		return
+	}
+
+	s.Failed++
+	if timedOut {
+		s.TimedOut++
+	}
+}
+
+type summaryDoc struct {
+	Timestamp string           `json:"@timestamp"`
+	Round     uint64           `json:"round"`
+	Seed      int64            `json:"seed"`
+	Start     string           `json:"start"`
+	End       string           `json:"end"`
+	Duration  float64          `json:"duration"`
+	Checks    int              `json:"checks"`
+	Passed    int              `json:"passed"`
+	Failed    int              `json:"failed"`
+	TimedOut  int              `json:"timed_out"`
+	Scores    map[string]int64 `json:"scores"`
+}
+
+// Document creates a JSON blob containing the round summary and the
+// destination index name for the summary document.
+func (s *Summary) Document() (string, io.Reader, error) {
+	body, err := json.Marshal(summaryDoc{
+		Timestamp: s.End.Format(time.RFC3339),
+		Round:     s.Round,
+		Seed:      s.Seed,
+		Start:     s.Start.Format(time.RFC3339Nano),
+		End:       s.End.Format(time.RFC3339Nano),
+		Duration:  s.End.Sub(s.Start).Seconds(),
+		Checks:    s.Checks,
+		Passed:    s.Passed,
+		Failed:    s.Failed,
+		TimedOut:  s.TimedOut,
+		Scores:    s.Scores,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal summary of round %d to JSON: %s", s.Round, err)
+	}
+
+	return "rounds", bytes.NewReader(body), nil
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// An EventType describes why the Scheduler recorded an Event.
+type EventType string
+
+const (
+	// LateStart events are recorded when a round starts more than
+	// LateThreshold after it was scheduled to start.
+	LateStart EventType = "late_start"
+
+	// Skipped events are recorded when a round is not run at all.
+	Skipped EventType = "skipped"
+)
+
+// An Event records something unusual that happened while scheduling rounds.
+type Event struct {
+	Timestamp time.Time
+	Round     uint64
+	Type      EventType
+	Delay     time.Duration
+	Message   string
+}
+
+type document struct {
+	Timestamp string    `json:"@timestamp"`
+	Round     uint64    `json:"round"`
+	Type      EventType `json:"type"`
+	DelayMs   int64     `json:"delay_ms"`
+	Message   string    `json:"message"`
+}
+
+// Document creates a JSON blob containing the event and the destination index
+// name for the event document.
+func (e *Event) Document() (string, io.Reader, error) {
+	body, err := json.Marshal(document{
+		Timestamp: e.Timestamp.Format(time.RFC3339),
+		Round:     e.Round,
+		Type:      e.Type,
+		DelayMs:   e.Delay.Milliseconds(),
+		Message:   e.Message,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal event to JSON: %s", err)
+	}
+
+	return "events", bytes.NewReader(body), nil
+}
@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// A Policy determines what the Scheduler does when it's time to start a round
+// but the previous round is still running.
+type Policy string
+
+const (
+	// Skip drops the new round. The next round will start on the following
+	// tick if the previous round has finished by then.
+	Skip Policy = "skip"
+
+	// Queue delays the new round until the previous round has finished. At
+	// most one round can be waiting to start; any rounds beyond that are
+	// skipped.
+	Queue Policy = "queue"
+
+	// Allow starts the new round immediately, so rounds may run
+	// concurrently.
+	Allow Policy = "allow"
+)
+
+// LateThreshold is how long after its scheduled time a round can start before
+// it is considered late.
+const LateThreshold = time.Second
+
+// ParsePolicy converts a policy name from the Dynamicbeat configuration into a
+// Policy.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case Skip, Queue, Allow:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown overlap policy '%s' - must be one of '%s', '%s', or '%s'", name, Skip, Queue, Allow)
+	}
+}
+
+// A RoundFunc runs a single round of checks. It must not return until all the
+// checks in the round have finished.
+type RoundFunc func(round uint64)
+
+// The Scheduler starts rounds of checks at a regular interval, numbering each
+// round and enforcing the configured overlap policy.
+type Scheduler struct {
+	Interval time.Duration // average time between the scheduled starts of each round
+	Jitter   time.Duration // each interval is randomly lengthened or shortened by up to this much
+	Seed     int64         // seeds the random interval lengths
+	Policy   Policy        // what to do when a round is due while another is running
+	Events   chan<- Event  // where late starts and skipped rounds are reported
+	Last     uint64        // the number of the last round run before the scheduler started
+
+	rng     *rand.Rand
+	round   uint64
+	running int
+	queued  *time.Time
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// Run starts rounds until the context is cancelled, and then waits for any
+// running rounds to finish before returning.
+func (s *Scheduler) Run(ctx context.Context, fn RoundFunc) {
+	s.round = s.Last
+	s.rng = rand.New(rand.NewSource(s.Seed))
+
+	timer := time.NewTimer(s.next())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drop any queued round, since we're shutting down
+			s.mu.Lock()
+			s.queued = nil
+			s.mu.Unlock()
+
+			s.wg.Wait()
+			return
+		case scheduled := <-timer.C:
+			timer.Reset(s.next())
+			s.tick(scheduled, fn)
+		}
+	}
+}
+
+// next picks a random amount of time to wait before the next round is due.
+func (s *Scheduler) next() time.Duration {
+	if s.Jitter <= 0 {
+		return s.Interval
+	}
+
+	interval := s.Interval + time.Duration(s.rng.Int63n(int64(2*s.Jitter)+1)) - s.Jitter
+	if interval <= 0 {
+		return s.Interval
+	}
+
+	return interval
+}
+
+func (s *Scheduler) tick(scheduled time.Time, fn RoundFunc) {
+	s.mu.Lock()
+	var event *Event
+	switch {
+	case s.running == 0 || s.Policy == Allow:
+		event = s.start(scheduled, fn)
+	case s.Policy == Queue && s.queued == nil:
+		// The previous round is still running
+		zap.S().Warnf("Previous round is still running, round will start when it finishes")
+		s.queued = &scheduled
+	default:
+		s.round++
+		zap.S().Warnf("Previous round is still running, skipping round %d", s.round)
+		event = &Event{
+			Timestamp: scheduled,
+			Round:     s.round,
+			Type:      Skipped,
+			Message:   fmt.Sprintf("round %d was skipped because %d round(s) were still running", s.round, s.running),
+		}
+	}
+	s.mu.Unlock()
+
+	s.report(event)
+}
+
+// start must be called with the mutex held. If the round is starting late, an
+// event is returned, which must be reported after the mutex is released.
+func (s *Scheduler) start(scheduled time.Time, fn RoundFunc) *Event {
+	s.round++
+	round := s.round
+	s.running++
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn(round)
+		s.finish(fn)
+	}()
+
+	// Record the start if it's later than it should be
+	delay := time.Since(scheduled)
+	if delay <= LateThreshold {
+		return nil
+	}
+	zap.S().Warnf("Round %d started %.2f seconds late", round, delay.Seconds())
+	return &Event{
+		Timestamp: time.Now(),
+		Round:     round,
+		Type:      LateStart,
+		Delay:     delay,
+		Message:   fmt.Sprintf("round %d started %.2f seconds after it was scheduled", round, delay.Seconds()),
+	}
+}
+
+func (s *Scheduler) finish(fn RoundFunc) {
+	s.mu.Lock()
+	var event *Event
+	s.running--
+	if s.queued != nil && s.running == 0 {
+		scheduled := *s.queued
+		s.queued = nil
+		event = s.start(scheduled, fn)
+	}
+	s.mu.Unlock()
+
+	s.report(event)
+}
+
+// report sends an event, if there is one. Events are sent without holding the
+// mutex, so a slow publisher can't block rounds from starting or finishing.
+func (s *Scheduler) report(event *Event) {
+	if event != nil {
+		s.Events <- *event
+	}
+}
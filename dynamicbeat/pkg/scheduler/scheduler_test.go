@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Policy
+		wantErr bool
+	}{
+		{"skip", Skip, false},
+		{"queue", Queue, false},
+		{"allow", Allow, false},
+		{"", "", true},
+		{"wait", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	s := &Scheduler{Interval: time.Second, Jitter: 100 * time.Millisecond, rng: rand.New(rand.NewSource(1))}
+	for i := 0; i < 100; i++ {
+		if got := s.next(); got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("next() = %s, want within 100ms of 1s", got)
+		}
+	}
+
+	s = &Scheduler{Interval: time.Second}
+	if got := s.next(); got != time.Second {
+		t.Errorf("next() without jitter = %s, want 1s", got)
+	}
+}
+
+// rounds runs rounds that block until they're released.
+type rounds struct {
+	started chan uint64
+	release chan struct{}
+}
+
+func newRounds() *rounds {
+	return &rounds{started: make(chan uint64, 10), release: make(chan struct{})}
+}
+
+func (r *rounds) run(round uint64) {
+	r.started <- round
+	<-r.release
+}
+
+// wait returns the number of the next round to start.
+func (r *rounds) wait(t *testing.T) uint64 {
+	t.Helper()
+	select {
+	case round := <-r.started:
+		return round
+	case <-time.After(time.Second):
+		t.Fatal("round did not start")
+		return 0
+	}
+}
+
+// event returns the next event that was reported.
+func event(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event was reported")
+		return Event{}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	events := make(chan Event, 10)
+	s := &Scheduler{Policy: Skip, Events: events, Last: 4}
+	s.round = s.Last
+	r := newRounds()
+
+	s.tick(time.Now(), r.run)
+	if got := r.wait(t); got != 5 {
+		t.Errorf("first round = %d, want 5", got)
+	}
+
+	// The round that's due while round 5 is running is skipped, but still
+	// uses up a round number
+	s.tick(time.Now(), r.run)
+	if e := event(t, events); e.Type != Skipped || e.Round != 6 {
+		t.Errorf("event = %s for round %d, want %s for round 6", e.Type, e.Round, Skipped)
+	}
+
+	close(r.release)
+	s.wg.Wait()
+	s.tick(time.Now(), r.run)
+	if got := r.wait(t); got != 7 {
+		t.Errorf("round after skipped round = %d, want 7", got)
+	}
+	s.wg.Wait()
+}
+
+func TestQueue(t *testing.T) {
+	events := make(chan Event, 10)
+	s := &Scheduler{Policy: Queue, Events: events}
+	r := newRounds()
+
+	s.tick(time.Now(), r.run)
+	r.wait(t)
+
+	// One round is queued, and any more are skipped
+	s.tick(time.Now(), r.run)
+	s.tick(time.Now(), r.run)
+	if e := event(t, events); e.Type != Skipped || e.Round != 2 {
+		t.Errorf("event = %s for round %d, want %s for round 2", e.Type, e.Round, Skipped)
+	}
+
+	// The queued round starts as soon as the running round finishes
+	close(r.release)
+	if got := r.wait(t); got != 3 {
+		t.Errorf("queued round = %d, want 3", got)
+	}
+	s.wg.Wait()
+	if len(events) != 0 {
+		t.Errorf("unexpected event: %+v", <-events)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	events := make(chan Event, 10)
+	s := &Scheduler{Policy: Allow, Events: events}
+	r := newRounds()
+
+	// Both rounds are running at the same time
+	s.tick(time.Now(), r.run)
+	s.tick(time.Now(), r.run)
+	if a, b := r.wait(t), r.wait(t); a+b != 3 {
+		t.Errorf("started rounds %d and %d, want 1 and 2", a, b)
+	}
+
+	close(r.release)
+	s.wg.Wait()
+	if len(events) != 0 {
+		t.Errorf("unexpected event: %+v", <-events)
+	}
+}
+
+func TestLateStart(t *testing.T) {
+	events := make(chan Event, 10)
+	s := &Scheduler{Policy: Skip, Events: events}
+	r := newRounds()
+	close(r.release)
+
+	s.tick(time.Now().Add(-2*time.Second), r.run)
+	e := event(t, events)
+	if e.Type != LateStart || e.Round != 1 {
+		t.Errorf("event = %s for round %d, want %s for round 1", e.Type, e.Round, LateStart)
+	}
+	if e.Delay < 2*time.Second {
+		t.Errorf("delay = %s, want at least 2s", e.Delay)
+	}
+	s.wg.Wait()
+}
+
+func TestSlowEvents(t *testing.T) {
+	// Nothing reads the events, so reporting one blocks
+	events := make(chan Event)
+	s := &Scheduler{Policy: Skip, Events: events}
+	r := newRounds()
+
+	s.tick(time.Now(), r.run)
+	r.wait(t)
+	go s.tick(time.Now(), r.run)
+
+	// The running round must still be able to finish while the skipped
+	// round's event is waiting to be sent
+	close(r.release)
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("round could not finish while an event was being reported")
+	}
+	<-events
+}
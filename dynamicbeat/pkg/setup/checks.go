@@ -7,6 +7,7 @@ import (
 
 	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/checksource"
+	"github.com/scorestack/scorestack/dynamicbeat/pkg/checktypes"
 	"github.com/scorestack/scorestack/dynamicbeat/pkg/esclient"
 	"go.uber.org/zap"
 )
@@ -26,6 +27,13 @@ func Checks(c *esclient.Client, f *checksource.Filesystem) error {
 	}
 
 	for _, def := range defs {
+		// Checks with an unknown type would fail every round
+		_, err = checktypes.GetCheckType(def)
+		if err != nil {
+			zap.S().Errorf("skipping check due to error - %s", err)
+			continue
+		}
+
 		chk, generic, admin, user, err := def.Documents()
 		if err != nil {
 			zap.S().Errorf("skipping check due to error - %s", err)
@@ -24,7 +24,7 @@ func Elasticsearch(c *esclient.Client, teams []config.Team) error {
 
 	// Add default index template
 	zap.S().Info("adding default index template")
-	idx := strings.NewReader(`{"index_patterns":["check*","attrib_*","results*"],"settings":{"number_of_replicas":"0"}}`)
+	idx := strings.NewReader(`{"index_patterns":["check*","attrib_*","results*","events","rounds"],"settings":{"number_of_replicas":"0"}}`)
 	res, err := c.Indices.PutTemplate("default", idx)
 	if err != nil {
 		return err
@@ -44,6 +44,16 @@ func Elasticsearch(c *esclient.Client, teams []config.Team) error {
 		return err
 	}
 
+	// Create scheduler events and round summary indices
+	err = c.AddIndex("events", indices.Events())
+	if err != nil {
+		return err
+	}
+	err = c.AddIndex("rounds", indices.Rounds())
+	if err != nil {
+		return err
+	}
+
 	for _, team := range teams {
 		zap.S().Infof("adding user and results index for %s", team.Name)
 		err = c.AddUser(team.Name, users.Team(team.Name))